@@ -0,0 +1,176 @@
+package fortuna
+
+import (
+	"context"
+	mrand "math/rand"
+	"sync"
+	"time"
+)
+
+// SaveInterval is the default interval between seed file updates;
+// the Fortuna designers recommend updating the seed file every ten
+// minutes.
+const SaveInterval = 10 * time.Minute
+
+// AutoUpdateConfig controls how an Updater saves the seed file. The
+// zero value saves the seed file every SaveInterval.
+type AutoUpdateConfig struct {
+	// Interval is the time between saves. If zero, SaveInterval
+	// is used.
+	Interval time.Duration
+
+	// Jitter adds a random delay of up to this duration to each
+	// interval, so that a fleet of hosts started together does
+	// not write their seed files in lockstep.
+	Jitter time.Duration
+
+	// Reseeds, if non-zero, triggers an additional save once the
+	// PRNG has been reseeded this many times since the last save.
+	Reseeds uint32
+
+	// Pools, if true, saves a sealed snapshot of the entropy pools
+	// alongside the seed file using WriteState; FromState should
+	// then be used to restore the PRNG on startup.
+	Pools bool
+
+	// OnError, if not nil, is called from the updater's goroutine
+	// with any error encountered while saving.
+	OnError func(error)
+
+	// Errors, if not nil, receives any error encountered while
+	// saving. Sends never block: if the channel is not ready, the
+	// error is discarded.
+	Errors chan<- error
+}
+
+// Updater periodically writes the PRNG's seed file in the
+// background. It is started with AutoUpdate.
+type Updater struct {
+	rng      *Fortuna
+	filename string
+	cfg      AutoUpdateConfig
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	lock    sync.Mutex
+	lastErr error
+	err     error
+}
+
+// AutoUpdate starts a goroutine that writes the PRNG's seed file
+// regularly, as recommended by the Fortuna designers. When ctx is
+// cancelled or Stop is called, the seed file is written one final
+// time. The PRNG itself is not shut down; callers should call Close
+// once they are done with it. If cfg is nil, the defaults are used.
+func (rng *Fortuna) AutoUpdate(ctx context.Context, filename string, cfg *AutoUpdateConfig) *Updater {
+	u := &Updater{
+		rng:      rng,
+		filename: filename,
+		done:     make(chan struct{}),
+	}
+	if cfg != nil {
+		u.cfg = *cfg
+	}
+	if u.cfg.Interval <= 0 {
+		u.cfg.Interval = SaveInterval
+	}
+
+	// Subscribe before starting the goroutine so that no reseed
+	// is missed between AutoUpdate returning and the first save.
+	var reseeded chan struct{}
+	if u.cfg.Reseeds > 0 {
+		reseeded = rng.notifyReseed()
+	}
+
+	ctx, u.cancel = context.WithCancel(ctx)
+	go u.run(ctx, reseeded, rng.reseeds())
+	return u
+}
+
+func (u *Updater) next() time.Duration {
+	d := u.cfg.Interval
+	if u.cfg.Jitter > 0 {
+		d += time.Duration(mrand.Int63n(int64(u.cfg.Jitter)))
+	}
+	return d
+}
+
+func (u *Updater) save() error {
+	var err error
+	if u.cfg.Pools {
+		err = u.rng.WriteState(u.filename)
+	} else {
+		err = u.rng.WriteSeed(u.filename)
+	}
+	u.lock.Lock()
+	u.lastErr = err
+	u.lock.Unlock()
+	if err == nil {
+		return nil
+	}
+
+	if u.cfg.OnError != nil {
+		u.cfg.OnError(err)
+	}
+	if u.cfg.Errors != nil {
+		select {
+		case u.cfg.Errors <- err:
+		default:
+		}
+	}
+	return err
+}
+
+func (u *Updater) run(ctx context.Context, reseeded chan struct{}, last uint32) {
+	defer close(u.done)
+	if reseeded != nil {
+		defer u.rng.stopNotify(reseeded)
+	}
+
+	timer := time.NewTimer(u.next())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			err := u.save()
+			u.lock.Lock()
+			u.err = err
+			u.lock.Unlock()
+			return
+		case <-timer.C:
+			u.save()
+			last = u.rng.reseeds()
+			timer.Reset(u.next())
+		case <-reseeded:
+			if n := u.rng.reseeds(); n-last >= u.cfg.Reseeds {
+				u.save()
+				last = n
+			}
+		}
+	}
+}
+
+// Err returns the result of the most recent save, or nil if the
+// seed file has not been written yet.
+func (u *Updater) Err() error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return u.lastErr
+}
+
+// Done returns a channel that is closed once the updater has
+// written its final seed file and exited.
+func (u *Updater) Done() <-chan struct{} {
+	return u.done
+}
+
+// Stop halts the updater and waits for the final save to finish,
+// returning its result. It is safe to call Stop more than once.
+func (u *Updater) Stop() error {
+	u.cancel()
+	<-u.done
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return u.err
+}
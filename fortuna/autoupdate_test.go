@@ -0,0 +1,135 @@
+package fortuna
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAutoUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	seedFile := filepath.Join(dir, "test.seed")
+
+	rng := seededRNG(t)
+	u := rng.AutoUpdate(context.Background(), seedFile, &AutoUpdateConfig{
+		Interval: 10 * time.Millisecond,
+	})
+	<-time.After(50 * time.Millisecond)
+	if err = u.Stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if err = u.Stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	if _, err = FromSeed(seedFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	if !rng.Initialised() {
+		fmt.Fprintf(os.Stderr, "fortuna: updater should not shut down the PRNG\n")
+		t.FailNow()
+	}
+}
+
+func TestAutoUpdateContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	seedFile := filepath.Join(dir, "test.seed")
+
+	rng := seededRNG(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	u := rng.AutoUpdate(ctx, seedFile, nil)
+	cancel()
+
+	select {
+	case <-u.Done():
+	case <-time.After(time.Second):
+		fmt.Fprintf(os.Stderr, "fortuna: updater did not stop on cancel\n")
+		t.FailNow()
+	}
+
+	if _, err = os.Stat(seedFile); err != nil {
+		fmt.Fprintf(os.Stderr, "fortuna: final save was not written (%v)\n", err)
+		t.FailNow()
+	}
+}
+
+func TestAutoUpdateReseeds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	seedFile := filepath.Join(dir, "test.seed")
+
+	rng := New()
+	u := rng.AutoUpdate(context.Background(), seedFile, &AutoUpdateConfig{
+		Reseeds: 1,
+	})
+	defer u.Stop()
+
+	rng.mu.Lock()
+	rng.reseed()
+	rng.mu.Unlock()
+
+	for i := 0; i < 100; i++ {
+		if _, err = os.Stat(seedFile); err == nil {
+			return
+		}
+		<-time.After(10 * time.Millisecond)
+	}
+	fmt.Fprintf(os.Stderr, "fortuna: seed file was not saved after reseed\n")
+	t.FailNow()
+}
+
+func TestAutoUpdateErrors(t *testing.T) {
+	seedFile := filepath.Join("nonexistent", "dir", "test.seed")
+	errs := make(chan error)
+	var reported int
+
+	rng := seededRNG(t)
+	u := rng.AutoUpdate(context.Background(), seedFile, &AutoUpdateConfig{
+		Interval: time.Millisecond,
+		Errors:   errs,
+		OnError:  func(error) { reported++ },
+	})
+	<-time.After(20 * time.Millisecond)
+
+	done := make(chan error)
+	go func() { done <- u.Stop() }()
+	select {
+	case err := <-done:
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "fortuna: final save should have failed\n")
+			t.FailNow()
+		}
+	case <-time.After(time.Second):
+		fmt.Fprintf(os.Stderr, "fortuna: updater deadlocked on error channel\n")
+		t.FailNow()
+	}
+
+	if reported == 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: OnError was never called\n")
+		t.FailNow()
+	} else if u.Err() == nil {
+		fmt.Fprintf(os.Stderr, "fortuna: Err should report the failed save\n")
+		t.FailNow()
+	}
+}
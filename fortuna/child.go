@@ -0,0 +1,163 @@
+package fortuna
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// ChildReseedInterval and ChildReseedBytes are the defaults for how
+// often a child generator reseeds from its parent: after the
+// interval has passed or the given number of bytes has been read,
+// whichever comes first.
+var (
+	ChildReseedInterval       = time.Minute
+	ChildReseedBytes    int64 = 1 << 20
+)
+
+// childDomain prefixes the label of a child generator when deriving
+// its key from the parent.
+const childDomain = "fortuna: child generator: "
+
+// ChildPolicy controls when a child generator reseeds from its
+// parent. A zero field disables that trigger.
+type ChildPolicy struct {
+	Interval time.Duration
+	Bytes    int64
+}
+
+// Child is an independent generator derived from a Fortuna PRNG,
+// such as one per tenant or subsystem. Its key is derived from the
+// parent's output and its label; the parent and child never share
+// key material, so a compromise of one child's state reveals
+// nothing about the parent's or any other child's output. A Child is
+// created with the Child method.
+type Child struct {
+	mu         sync.Mutex
+	parent     *Fortuna
+	label      string
+	policy     ChildPolicy
+	g          *Generator
+	closed     bool
+	epoch      uint32
+	lastReseed time.Time
+	read       int64
+}
+
+// Child returns a new generator derived from the PRNG for the given
+// label, reseeding from the parent using the default policy. The
+// parent must be seeded.
+func (rng *Fortuna) Child(label string) (*Child, error) {
+	if !rng.Initialised() {
+		return nil, ErrNotInitialised
+	}
+
+	c := &Child{
+		parent: rng,
+		label:  label,
+		policy: ChildPolicy{
+			Interval: ChildReseedInterval,
+			Bytes:    ChildReseedBytes,
+		},
+		g: NewGenerator(),
+	}
+	if err := c.reseed(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Label returns the label the child was derived with.
+func (c *Child) Label() string {
+	return c.label
+}
+
+// SetPolicy changes when the child reseeds from its parent.
+func (c *Child) SetPolicy(policy ChildPolicy) {
+	c.mu.Lock()
+	c.policy = policy
+	c.mu.Unlock()
+}
+
+// reseed mixes fresh key material from the parent into the child's
+// generator. The caller must hold the child's lock, except during
+// construction.
+func (c *Child) reseed() error {
+	epoch := c.parent.invalidations()
+	k, err := c.parent.deriveSeed(childDomain+c.label, len(rngKey{}))
+	if err != nil {
+		return err
+	}
+	c.g.Write(k)
+	zero(k)
+
+	c.epoch = epoch
+	c.lastReseed = time.Now()
+	c.read = 0
+	return nil
+}
+
+func (c *Child) mustReseed() bool {
+	if c.parent.invalidations() != c.epoch {
+		return true
+	}
+	if c.policy.Interval > 0 && time.Since(c.lastReseed) >= c.policy.Interval {
+		return true
+	}
+	return c.policy.Bytes > 0 && c.read >= c.policy.Bytes
+}
+
+// Reseed forces the child to reseed from its parent.
+func (c *Child) Reseed() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrNotInitialised
+	}
+	return c.reseed()
+}
+
+// Read fills p with random data from the child, reseeding from the
+// parent first if the policy requires it. If the parent's state has
+// been invalidated, such as after a VM clone is detected, the child
+// fails until the parent can provide fresh key material.
+func (c *Child) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, ErrNotInitialised
+	}
+
+	if c.mustReseed() {
+		if err := c.reseed(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := c.g.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
+// Seed returns a byte slice containing a seed produced by the child,
+// in the same format as the seed returned by the Fortuna type.
+func (c *Child) Seed() ([]byte, error) {
+	var p = make([]byte, SeedFileLength)
+	if _, err := io.ReadFull(c, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Close shuts down the child and erases its key; it does not affect
+// the parent.
+func (c *Child) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrNotInitialised
+	}
+	c.closed = true
+	zero(c.g.key[:])
+	return nil
+}
@@ -0,0 +1,102 @@
+package fortuna
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestChild(t *testing.T) {
+	if _, err := New().Child("tenant"); err != ErrNotSeeded {
+		fmt.Fprintf(os.Stderr, "fortuna: unseeded PRNG should not create children\n")
+		t.FailNow()
+	}
+
+	rng := seededRNG(t)
+	a, err := rng.Child("tenant-a")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	b, err := rng.Child("tenant-b")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	if bytes.Equal(a.g.key[:], b.g.key[:]) || bytes.Equal(a.g.key[:], rng.g.key[:]) {
+		fmt.Fprintf(os.Stderr, "fortuna: child generators share key material\n")
+		t.FailNow()
+	}
+
+	var pa = make([]byte, 64)
+	var pb = make([]byte, 64)
+	if _, err = a.Read(pa); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if _, err = b.Read(pb); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if bytes.Equal(pa, pb) {
+		fmt.Fprintf(os.Stderr, "fortuna: child generators produced the same output\n")
+		t.FailNow()
+	}
+
+	if seed, err := a.Seed(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if len(seed) != SeedFileLength {
+		fmt.Fprintf(os.Stderr, "fortuna: bad child seed length\n")
+		t.FailNow()
+	}
+
+	if err = a.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if _, err = a.Read(pa); err != ErrNotInitialised {
+		fmt.Fprintf(os.Stderr, "fortuna: closed child should not provide output\n")
+		t.FailNow()
+	} else if _, err = b.Read(pb); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+}
+
+func TestChildReseedPolicy(t *testing.T) {
+	rng := seededRNG(t)
+	c, err := rng.Child("subsystem")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	c.SetPolicy(ChildPolicy{Bytes: 32})
+
+	var p = make([]byte, 32)
+	c.Read(p)
+	last := c.lastReseed
+	<-time.After(time.Millisecond)
+	if _, err = c.Read(p); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if !c.lastReseed.After(last) {
+		fmt.Fprintf(os.Stderr, "fortuna: child did not reseed after policy bytes\n")
+		t.FailNow()
+	}
+
+	// A child must not keep producing output from a state that
+	// may have been cloned along with its parent.
+	c.SetPolicy(ChildPolicy{})
+	rng.invalidate()
+	if _, err = c.Read(p); err != ErrStale {
+		fmt.Fprintf(os.Stderr, "fortuna: child should fail while parent is stale\n")
+		t.FailNow()
+	}
+
+	rng.freshReseed(make([]byte, 64))
+	if _, err = c.Read(p); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,280 @@
+package fortuna
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CommandInterval and CommandTimeout are the default interval between
+// runs of a command and the time it may run before it is killed.
+const (
+	CommandInterval = time.Minute
+	CommandTimeout  = 10 * time.Second
+)
+
+// CommandOutputLimit is the most output, in bytes, read from a single
+// run of a command; anything more is discarded.
+const CommandOutputLimit = 1 << 20
+
+// commandWaitDelay is the time allowed for a killed command's output
+// to be closed; a child that keeps the output open any longer is
+// abandoned.
+const commandWaitDelay = time.Second
+
+// CommandEnv is the environment commands are run with. Commands do
+// not inherit the process's environment, and are looked up in the
+// PATH given here rather than the process's own.
+var CommandEnv = []string{
+	"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+	"LC_ALL=C",
+}
+
+var ErrInvalidCommand = errors.New("fortuna: invalid command configuration")
+
+// Command describes a command run periodically by the command source,
+// such as "ps auxww" or "vmstat".
+type Command struct {
+	// Name is the name the command's source is registered under.
+	// If empty, "command:" followed by the path is used.
+	Name string
+
+	// Path is the command to run, and Args are its arguments.
+	// Path is either absolute, or a bare name that is looked up in
+	// the PATH from CommandEnv each time the command is run.
+	Path string
+	Args []string
+
+	// Interval is the time between runs. If zero,
+	// CommandInterval is used.
+	Interval time.Duration
+
+	// Timeout is the time the command may run before it, and any
+	// children it started, are killed. If zero, CommandTimeout is
+	// used.
+	Timeout time.Duration
+
+	// Entropy is the min-entropy of each byte of output, in bits.
+	// The output is hashed into a single event, which is credited
+	// with at most 256 bits however much the command printed. The
+	// default of zero credits none.
+	Entropy float64
+}
+
+// commandConfig is the JSON form of a command, with durations given
+// as strings such as "30s".
+type commandConfig struct {
+	Name     string   `json:"name"`
+	Path     string   `json:"path"`
+	Args     []string `json:"args"`
+	Interval string   `json:"interval"`
+	Timeout  string   `json:"timeout"`
+	Entropy  float64  `json:"entropy"`
+}
+
+// ReadCommands reads a list of commands from JSON, so that collection
+// can be tuned for each host without recompiling. The input has the
+// form
+//
+//	{"commands": [
+//		{"path": "ps", "args": ["auxww"], "interval": "30s"},
+//		{"name": "sockets", "path": "ss", "args": ["-tan"], "timeout": "5s"}
+//	]}
+func ReadCommands(r io.Reader) ([]Command, error) {
+	var cfg struct {
+		Commands []commandConfig `json:"commands"`
+	}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, err
+	}
+
+	var cmds []Command
+	for _, cc := range cfg.Commands {
+		if !validCommandPath(cc.Path) || cc.Entropy < 0 || cc.Entropy > 8 {
+			return nil, ErrInvalidCommand
+		}
+
+		cmd := Command{
+			Name:    cc.Name,
+			Path:    cc.Path,
+			Args:    cc.Args,
+			Entropy: cc.Entropy,
+		}
+
+		var err error
+		if cc.Interval != "" {
+			if cmd.Interval, err = time.ParseDuration(cc.Interval); err != nil {
+				return nil, err
+			}
+		}
+		if cc.Timeout != "" {
+			if cmd.Timeout, err = time.ParseDuration(cc.Timeout); err != nil {
+				return nil, err
+			}
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+// ReadCommandFile reads a list of commands from a JSON file, as
+// described for ReadCommands.
+func ReadCommandFile(filename string) ([]Command, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadCommands(f)
+}
+
+// validCommandPath reports whether path is an absolute path or a bare
+// command name. Relative paths would depend on the working directory.
+func validCommandPath(path string) bool {
+	if filepath.IsAbs(path) {
+		return true
+	}
+	return path != "" && !strings.ContainsAny(path, "/"+string(filepath.Separator))
+}
+
+// lookCommand resolves a bare command name against the PATH in env,
+// ignoring the process's own PATH. Relative directories in the PATH
+// are skipped.
+func lookCommand(name string, env []string) (string, error) {
+	if filepath.IsAbs(name) {
+		return name, nil
+	}
+
+	var path string
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "PATH=") {
+			path = kv[len("PATH="):]
+		}
+	}
+	for _, dir := range filepath.SplitList(path) {
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		file := filepath.Join(dir, name)
+		if fi, err := os.Stat(file); err == nil && executable(fi) {
+			return file, nil
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// limitedBuffer keeps the first limit bytes written to it, silently
+// discarding the rest so that the command is not blocked.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if n := b.limit - b.Len(); n < len(p) {
+		if n > 0 {
+			b.Buffer.Write(p[:n])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+type commandPoller struct {
+	cmd Command
+	n   int
+}
+
+// Poll runs the command and hashes its output together with the
+// times it started and took to run, and its exit status, into an
+// event. A command that exits with an error still produces an event;
+// one that times out does not.
+func (p *commandPoller) Poll(ctx context.Context) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.cmd.Timeout)
+	defer cancel()
+
+	path, err := lookCommand(p.cmd.Path, CommandEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	var out = &limitedBuffer{limit: CommandOutputLimit}
+	cmd := exec.CommandContext(ctx, path, p.cmd.Args...)
+	cmd.Args[0] = p.cmd.Path
+	cmd.Env = CommandEnv
+	cmd.Dir = "/"
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.WaitDelay = commandWaitDelay
+	isolate(cmd)
+
+	start := time.Now()
+	err = cmd.Run()
+	elapsed := time.Since(start)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	} else if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return nil, err
+	}
+
+	p.n = out.Len()
+	var buf [8]byte
+	h := sha256.New()
+	binary.LittleEndian.PutUint64(buf[:], uint64(start.UnixNano()))
+	h.Write(buf[:])
+	binary.LittleEndian.PutUint64(buf[:], uint64(elapsed))
+	h.Write(buf[:])
+	binary.LittleEndian.PutUint64(buf[:], uint64(cmd.ProcessState.ExitCode()))
+	h.Write(buf[:])
+	h.Write(out.Bytes())
+	return h.Sum(nil), nil
+}
+
+// collected returns the number of bytes of output from the last run,
+// to which the command's entropy is credited.
+func (p *commandPoller) collected() int {
+	return p.n
+}
+
+// AddCommands adds a poller for each command, each registered as its
+// own source. Commands run with CommandEnv as their environment, in
+// their own process group, from the root directory. A command's path
+// must be absolute or a bare name, which is looked up in the PATH from
+// CommandEnv.
+func (r *PollRunner) AddCommands(cmds []Command, opts *SourceOptions) error {
+	for _, cmd := range cmds {
+		if !validCommandPath(cmd.Path) {
+			return ErrInvalidCommand
+		}
+		if cmd.Name == "" {
+			cmd.Name = "command:" + cmd.Path
+		}
+		if cmd.Interval <= 0 {
+			cmd.Interval = CommandInterval
+		}
+		if cmd.Timeout <= 0 {
+			cmd.Timeout = CommandTimeout
+		}
+
+		err := r.Add(cmd.Name, &commandPoller{cmd: cmd}, &PollerConfig{
+			Interval: cmd.Interval,
+			Entropy:  cmd.Entropy,
+			Source:   opts,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package fortuna
+
+import (
+	"os"
+	"os/exec"
+)
+
+// isolate does nothing on platforms without process groups; only the
+// command itself is killed if it is cancelled.
+func isolate(cmd *exec.Cmd) {}
+
+// executable reports whether fi is a file that may be executed.
+func executable(fi os.FileInfo) bool {
+	return fi.Mode().IsRegular()
+}
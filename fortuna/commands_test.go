@@ -0,0 +1,177 @@
+package fortuna
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCommands(t *testing.T) {
+	cmds, err := ReadCommands(strings.NewReader(`{"commands": [
+		{"path": "ps", "args": ["auxww"], "interval": "30s"},
+		{"name": "sockets", "path": "ss", "args": ["-tan"], "timeout": "5s", "entropy": 0.5}
+	]}`))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if len(cmds) != 2 {
+		fmt.Fprintf(os.Stderr, "fortuna: read %d commands, expected 2\n", len(cmds))
+		t.FailNow()
+	}
+
+	if cmds[0].Path != "ps" || cmds[0].Args[0] != "auxww" || cmds[0].Interval != 30*time.Second {
+		fmt.Fprintf(os.Stderr, "fortuna: bad command %+v\n", cmds[0])
+		t.FailNow()
+	} else if cmds[1].Name != "sockets" || cmds[1].Timeout != 5*time.Second || cmds[1].Entropy != 0.5 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad command %+v\n", cmds[1])
+		t.FailNow()
+	}
+
+	var invalid = []string{
+		`{"commands": [{"args": ["-a"]}]}`,
+		`{"commands": [{"path": "ps", "interval": "often"}]}`,
+		`{"commands": [{"path": "ps", "user": "root"}]}`,
+		`{"commands": [{"path": "bin/ps"}]}`,
+	}
+	for _, s := range invalid {
+		if _, err = ReadCommands(strings.NewReader(s)); err == nil {
+			fmt.Fprintf(os.Stderr, "fortuna: invalid command config accepted: %s\n", s)
+			t.FailNow()
+		}
+	}
+}
+
+func TestCommandPoller(t *testing.T) {
+	p := &commandPoller{cmd: Command{
+		Path:    "sh",
+		Args:    []string{"-c", "echo $HOME; exit 3"},
+		Timeout: time.Second,
+	}}
+	out, err := p.Poll(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fortuna: failing command should still produce an event (%v)\n", err)
+		t.FailNow()
+	} else if len(out) != MaxEventSize {
+		fmt.Fprintf(os.Stderr, "fortuna: command produced %d bytes, expected %d\n", len(out), MaxEventSize)
+		t.FailNow()
+	}
+
+	// A hung command, and the children it started, are killed
+	// at the timeout.
+	p = &commandPoller{cmd: Command{
+		Path:    "sh",
+		Args:    []string{"-c", "sleep 10 & sleep 10"},
+		Timeout: 50 * time.Millisecond,
+	}}
+	start := time.Now()
+	if _, err = p.Poll(context.Background()); err != context.DeadlineExceeded {
+		fmt.Fprintf(os.Stderr, "fortuna: hung command should time out (%v)\n", err)
+		t.FailNow()
+	} else if time.Since(start) > 2*time.Second {
+		fmt.Fprintf(os.Stderr, "fortuna: hung command was not killed\n")
+		t.FailNow()
+	}
+
+	p = &commandPoller{cmd: Command{Path: "/nonexistent", Timeout: time.Second}}
+	if _, err = p.Poll(context.Background()); err == nil {
+		fmt.Fprintf(os.Stderr, "fortuna: missing command should fail\n")
+		t.FailNow()
+	}
+}
+
+func TestCommandSource(t *testing.T) {
+	rng := New()
+	r := rng.StartPollers(context.Background(), nil)
+	defer r.Stop()
+	err := r.AddCommands([]Command{{Path: "true", Interval: time.Hour}}, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	if ss := waitForEvents(t, rng, 1); ss.Name != "command:true" {
+		fmt.Fprintf(os.Stderr, "fortuna: bad command source stats %+v\n", ss)
+		t.FailNow()
+	}
+}
+
+func TestCommandPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "fortuna-cmd"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	// Commands are looked up in the PATH from CommandEnv, never in
+	// the process's own.
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir)
+	if _, err = lookCommand("fortuna-cmd", CommandEnv); err == nil {
+		fmt.Fprintf(os.Stderr, "fortuna: command was looked up in the process's PATH\n")
+		t.FailNow()
+	} else if file, err := lookCommand("fortuna-cmd", []string{"PATH=.:" + dir}); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if file != filepath.Join(dir, "fortuna-cmd") {
+		fmt.Fprintf(os.Stderr, "fortuna: command resolved to %s\n", file)
+		t.FailNow()
+	}
+
+	os.Setenv("PATH", "/nonexistent")
+	p := &commandPoller{cmd: Command{Path: "true", Timeout: time.Second}}
+	if _, err = p.Poll(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "fortuna: command should be found in CommandEnv (%v)\n", err)
+		t.FailNow()
+	}
+
+	rng := New()
+	r := rng.StartPollers(context.Background(), nil)
+	defer r.Stop()
+	if err = r.AddCommands([]Command{{Path: "./true"}}, nil); err != ErrInvalidCommand {
+		fmt.Fprintf(os.Stderr, "fortuna: relative command path should be rejected (%v)\n", err)
+		t.FailNow()
+	}
+}
+
+func TestCommandEntropy(t *testing.T) {
+	// Entropy is credited for each byte the command printed, not
+	// for each byte of the digest, up to the size of the digest.
+	var digest = make([]byte, MaxEventSize)
+	if bits := credit(&commandPoller{n: 1000}, digest, 8) * MaxEventSize; bits != 256 {
+		fmt.Fprintf(os.Stderr, "fortuna: credited %v bits, expected 256\n", bits)
+		t.FailNow()
+	} else if bits = credit(&commandPoller{}, digest, 8); bits != 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: credited %v bits for no output\n", bits)
+		t.FailNow()
+	}
+
+	rng := New()
+	r := rng.StartPollers(context.Background(), nil)
+	defer r.Stop()
+	err := r.AddCommands([]Command{{
+		Path:     "printf",
+		Args:     []string{"0123456789"},
+		Interval: time.Hour,
+		Entropy:  0.5,
+	}}, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	if ss := waitForEvents(t, rng, 1); ss.Entropy != 5 {
+		fmt.Fprintf(os.Stderr, "fortuna: command credited %d bits, expected 5\n", ss.Entropy)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package fortuna
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// isolate runs cmd in its own process group, and kills the whole
+// group if the command is cancelled, so that children left behind by
+// a hung command are killed with it.
+func isolate(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// executable reports whether fi is a file that may be executed.
+func executable(fi os.FileInfo) bool {
+	return fi.Mode().IsRegular() && fi.Mode()&0111 != 0
+}
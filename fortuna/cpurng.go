@@ -0,0 +1,148 @@
+package fortuna
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// CPUSourceName is the name the CPU random number source is
+// registered under.
+const CPUSourceName = "cpu"
+
+// CPUInterval is the default interval between polls of the CPU
+// source.
+const CPUInterval = 10 * time.Second
+
+// cpuRetries is the number of times an instruction is retried when
+// it fails to return a value, as recommended by Intel for RDRAND.
+const cpuRetries = 10
+
+var (
+	ErrNoCPURNG = errors.New("fortuna: CPU random number instructions not available")
+	ErrCPURNG   = errors.New("fortuna: CPU random number instructions failed")
+)
+
+var cpuHasRDRAND, cpuHasRDSEED = cpuFeatures()
+
+// CPURNGAvailable reports whether the RDRAND and RDSEED instructions
+// may be used. Both are always false on architectures other than
+// amd64, and in builds with the purego tag.
+func CPURNGAvailable() (rdrand, rdseed bool) {
+	return cpuHasRDRAND, cpuHasRDSEED
+}
+
+// CPUSourceConfig controls the CPU random number source.
+type CPUSourceConfig struct {
+	// Size is the number of bytes read on each poll. If zero,
+	// MaxEventSize is used.
+	Size int
+
+	// Interval is the time between polls. If zero, CPUInterval is
+	// used.
+	Interval time.Duration
+
+	// Entropy is the min-entropy credited to each byte, in bits.
+	// The default of zero credits none, so that the CPU can never
+	// be the only contributor to a readiness policy's MinEntropy.
+	Entropy float64
+
+	// Source configures the registered source.
+	Source *SourceOptions
+}
+
+// cpuPoller reads from RDSEED, falling back to RDRAND when RDSEED is
+// unavailable or exhausted.
+type cpuPoller struct {
+	size   int
+	rdseed func() (uint64, bool)
+	rdrand func() (uint64, bool)
+	last   uint64
+}
+
+func newCPUPoller(size int) *cpuPoller {
+	p := &cpuPoller{size: size}
+	if cpuHasRDSEED {
+		p.rdseed = rdseed64
+	}
+	if cpuHasRDRAND {
+		p.rdrand = rdrand64
+	}
+	return p
+}
+
+// retry executes an instruction until it signals success, up to
+// cpuRetries times.
+func retry(f func() (uint64, bool)) (uint64, bool) {
+	if f == nil {
+		return 0, false
+	}
+	for i := 0; i < cpuRetries; i++ {
+		if v, ok := f(); ok {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// read64 returns the next value from the CPU. Values that are all
+// zeros or all ones, or that repeat the last value, are treated as a
+// failed health test: some CPUs have been known to return them while
+// signalling success.
+func (p *cpuPoller) read64() (uint64, error) {
+	v, ok := retry(p.rdseed)
+	if !ok {
+		v, ok = retry(p.rdrand)
+	}
+	if !ok {
+		return 0, ErrCPURNG
+	}
+
+	if v == 0 || v == ^uint64(0) || v == p.last {
+		return 0, ErrHealthTest
+	}
+	p.last = v
+	return v, nil
+}
+
+func (p *cpuPoller) Poll(ctx context.Context) ([]byte, error) {
+	buf := make([]byte, (p.size+7)/8*8)
+	for i := 0; i < len(buf); i += 8 {
+		v, err := p.read64()
+		if err != nil {
+			return nil, err
+		}
+		binary.LittleEndian.PutUint64(buf[i:], v)
+	}
+	return buf[:p.size], nil
+}
+
+// AddCPU adds a poller that reads from the CPU's RDSEED instruction,
+// falling back to RDRAND, registered as CPUSourceName. It returns
+// ErrNoCPURNG if neither instruction is available. The CPU is only
+// one source among many: its output is mixed into the pools like any
+// other source's, and by default it is credited with no entropy. If
+// cfg is nil, the defaults are used.
+func (r *PollRunner) AddCPU(cfg *CPUSourceConfig) error {
+	if !cpuHasRDRAND && !cpuHasRDSEED {
+		return ErrNoCPURNG
+	}
+
+	var cc CPUSourceConfig
+	if cfg != nil {
+		cc = *cfg
+	}
+	if cc.Size <= 0 {
+		cc.Size = MaxEventSize
+	}
+	if cc.Interval <= 0 {
+		cc.Interval = CPUInterval
+	}
+
+	return r.Add(CPUSourceName, newCPUPoller(cc.Size), &PollerConfig{
+		Interval: cc.Interval,
+		Entropy:  cc.Entropy,
+		Source:   cc.Source,
+	})
+}
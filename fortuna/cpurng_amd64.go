@@ -0,0 +1,28 @@
+//go:build amd64 && !purego
+
+package fortuna
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+// rdrand64 and rdseed64 execute RDRAND and RDSEED, returning false
+// if the instruction did not set the carry flag to signal a valid
+// result.
+func rdrand64() (v uint64, ok bool)
+func rdseed64() (v uint64, ok bool)
+
+// cpuFeatures reports whether the CPU supports RDRAND (CPUID leaf 1,
+// ECX bit 30) and RDSEED (CPUID leaf 7, EBX bit 18).
+func cpuFeatures() (rdrand, rdseed bool) {
+	max, _, _, _ := cpuid(0, 0)
+	if max < 1 {
+		return false, false
+	}
+
+	_, _, ecx, _ := cpuid(1, 0)
+	rdrand = ecx&(1<<30) != 0
+	if max >= 7 {
+		_, ebx, _, _ := cpuid(7, 0)
+		rdseed = ebx&(1<<18) != 0
+	}
+	return rdrand, rdseed
+}
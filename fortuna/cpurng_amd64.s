@@ -0,0 +1,28 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func rdrand64() (v uint64, ok bool)
+TEXT ·rdrand64(SB), NOSPLIT, $0-9
+	RDRANDQ AX
+	SETCS ok+8(FP)
+	MOVQ AX, v+0(FP)
+	RET
+
+// func rdseed64() (v uint64, ok bool)
+TEXT ·rdseed64(SB), NOSPLIT, $0-9
+	RDSEEDQ AX
+	SETCS ok+8(FP)
+	MOVQ AX, v+0(FP)
+	RET
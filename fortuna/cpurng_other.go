@@ -0,0 +1,12 @@
+//go:build !amd64 || purego
+
+package fortuna
+
+// Without the amd64 assembly, the CPU source is never available.
+
+func rdrand64() (uint64, bool) { return 0, false }
+func rdseed64() (uint64, bool) { return 0, false }
+
+func cpuFeatures() (rdrand, rdseed bool) {
+	return false, false
+}
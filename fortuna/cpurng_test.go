@@ -0,0 +1,90 @@
+package fortuna
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCPUPoller(t *testing.T) {
+	var seeds, rands int
+	var v uint64
+	p := &cpuPoller{
+		size: 20,
+		rdseed: func() (uint64, bool) {
+			// RDSEED is exhausted on every other call.
+			seeds++
+			if seeds%2 == 0 {
+				return 0, false
+			}
+			v++
+			return v, true
+		},
+		rdrand: func() (uint64, bool) {
+			rands++
+			v++
+			return v, true
+		},
+	}
+
+	buf, err := p.Poll(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if len(buf) != 20 {
+		fmt.Fprintf(os.Stderr, "fortuna: CPU poller returned %d bytes, expected 20\n", len(buf))
+		t.FailNow()
+	} else if rands != 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: RDSEED should be retried before falling back\n")
+		t.FailNow()
+	}
+
+	p.rdseed = func() (uint64, bool) { return 0, false }
+	if _, err = p.Poll(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if rands == 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: exhausted RDSEED should fall back to RDRAND\n")
+		t.FailNow()
+	}
+
+	p.rdrand = nil
+	if _, err = p.Poll(context.Background()); err != ErrCPURNG {
+		fmt.Fprintf(os.Stderr, "fortuna: failed instructions should be reported (%v)\n", err)
+		t.FailNow()
+	}
+
+	// A CPU that signals success but returns all ones fails the
+	// health check.
+	p.rdseed = func() (uint64, bool) { return ^uint64(0), true }
+	if _, err = p.Poll(context.Background()); err != ErrHealthTest {
+		fmt.Fprintf(os.Stderr, "fortuna: stuck CPU should fail health check (%v)\n", err)
+		t.FailNow()
+	}
+}
+
+func TestCPUSource(t *testing.T) {
+	rng := New()
+	r := rng.StartPollers(context.Background(), nil)
+	defer r.Stop()
+
+	rdrand, rdseed := CPURNGAvailable()
+	err := r.AddCPU(&CPUSourceConfig{Interval: time.Hour})
+	if !rdrand && !rdseed {
+		if err != ErrNoCPURNG {
+			fmt.Fprintf(os.Stderr, "fortuna: CPU source should be unavailable (%v)\n", err)
+			t.FailNow()
+		}
+		return
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	if ss := waitForEvents(t, rng, 1); ss.Name != CPUSourceName || ss.Entropy != 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad CPU source stats %+v\n", ss)
+		t.FailNow()
+	}
+}
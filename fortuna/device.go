@@ -0,0 +1,240 @@
+package fortuna
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultDevicePath is the default device read by the device source:
+// the Linux hardware RNG.
+const DefaultDevicePath = "/dev/hwrng"
+
+// DeviceBlockSize and DeviceInterval are the default number of bytes
+// read from a device on each poll and the interval between polls.
+const (
+	DeviceBlockSize = MaxEventSize
+	DeviceInterval  = time.Second
+)
+
+var ErrDeviceExhausted = errors.New("fortuna: device file exhausted")
+
+// DeviceSourceConfig controls a device source.
+type DeviceSourceConfig struct {
+	// Path is the device or file to read. If empty,
+	// DefaultDevicePath is used.
+	Path string
+
+	// Name is the name the source is registered under. If empty,
+	// "device:" followed by the path is used.
+	Name string
+
+	// BlockSize is the number of bytes read on each poll. If
+	// zero, DeviceBlockSize is used.
+	BlockSize int
+
+	// Interval is the time between polls. If zero, DeviceInterval
+	// is used.
+	Interval time.Duration
+
+	// Whiten, if true, compresses each block with SHA-256 before
+	// it is added to the PRNG.
+	Whiten bool
+
+	// Entropy is the device's min-entropy per byte, in bits. It
+	// is credited to the events added, and sets the cutoffs of
+	// the health tests run on the raw data; if zero, no entropy
+	// is credited and the package HealthTestEntropy is used for
+	// the tests.
+	Entropy float64
+
+	// Source configures the registered source.
+	Source *SourceOptions
+}
+
+// devicePoller reads blocks from a device, running the health tests
+// on the raw data. If the device fails or disappears, it is closed and
+// reopened on the next poll. A regular file is never read twice: at
+// its end, it stays open so that only data appended to it is read,
+// and it is only reopened once the path names a different file.
+type devicePoller struct {
+	path   string
+	size   int
+	whiten bool
+	test   *healthTest
+
+	f    *os.File
+	fi   os.FileInfo
+	read chan deviceRead
+}
+
+// deviceRead is the result of a read from a device.
+type deviceRead struct {
+	buf []byte
+	err error
+}
+
+func newDevicePoller(path string, size int, whiten bool, h float64) *devicePoller {
+	return &devicePoller{
+		path:   path,
+		size:   size,
+		whiten: whiten,
+		test:   newHealthTest(h),
+	}
+}
+
+// start begins reading a block from the device in the background,
+// opening it if needed.
+func (p *devicePoller) start() error {
+	if p.regular() {
+		fi, err := os.Stat(p.path)
+		if err != nil || !os.SameFile(fi, p.fi) {
+			p.Close()
+		}
+	}
+
+	if p.f == nil {
+		f, err := os.Open(p.path)
+		if err != nil {
+			return err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		p.f, p.fi = f, fi
+	}
+
+	f, read := p.f, make(chan deviceRead, 1)
+	p.read = read
+	go func() {
+		buf := make([]byte, p.size)
+		_, err := io.ReadFull(f, buf)
+		read <- deviceRead{buf, err}
+	}()
+	return nil
+}
+
+func (p *devicePoller) Poll(ctx context.Context) ([]byte, error) {
+	if p.read == nil {
+		if err := p.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Most hardware RNGs can't be used with the runtime poller, so
+	// a read from one that has stopped responding blocks, and
+	// closing the device would wait for it. The read runs in its own
+	// goroutine instead: if ctx is cancelled first, it is left to
+	// finish in the background, and its block is returned by the
+	// next poll.
+	var r deviceRead
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r = <-p.read:
+		p.read = nil
+	}
+	if r.err != nil {
+		if p.regular() && (r.err == io.EOF || r.err == io.ErrUnexpectedEOF) {
+			return nil, ErrDeviceExhausted
+		}
+		p.Close()
+		return nil, r.err
+	}
+
+	buf := r.buf
+	for _, b := range buf {
+		if !p.test.sample(b) {
+			return nil, ErrHealthTest
+		}
+	}
+
+	if p.whiten {
+		var out []byte
+		for len(buf) > 0 {
+			n := MaxEventSize
+			if n > len(buf) {
+				n = len(buf)
+			}
+			sum := sha256.Sum256(buf[:n])
+			out = append(out, sum[:]...)
+			buf = buf[n:]
+		}
+		buf = out
+	}
+	return buf, nil
+}
+
+// regular returns true if the open device is a regular file rather
+// than a character device or FIFO.
+func (p *devicePoller) regular() bool {
+	return p.f != nil && p.fi != nil && p.fi.Mode().IsRegular()
+}
+
+// Close closes the device, if it is open. If a read is still in
+// progress, the device is closed once it returns, so that Close never
+// blocks on a stalled device.
+func (p *devicePoller) Close() error {
+	if p.f == nil {
+		return nil
+	}
+	f, read := p.f, p.read
+	p.f, p.fi, p.read = nil, nil, nil
+	if read != nil {
+		go func() {
+			<-read
+			f.Close()
+		}()
+		return nil
+	}
+	return f.Close()
+}
+
+// AddDevice adds a poller that reads blocks from a hardware RNG
+// device, or any file or FIFO. If the device disappears, polls fail
+// and back off until it can be opened again. Once a regular file has
+// been read to its end, polls fail with ErrDeviceExhausted until more
+// data is appended or the path names a different file. If cfg is nil, the
+// defaults are used.
+func (r *PollRunner) AddDevice(cfg *DeviceSourceConfig) error {
+	var dc DeviceSourceConfig
+	if cfg != nil {
+		dc = *cfg
+	}
+	if dc.Path == "" {
+		dc.Path = DefaultDevicePath
+	}
+	if dc.Name == "" {
+		dc.Name = "device:" + dc.Path
+	}
+	if dc.BlockSize <= 0 {
+		dc.BlockSize = DeviceBlockSize
+	}
+	if dc.Interval <= 0 {
+		dc.Interval = DeviceInterval
+	}
+
+	// A whitened digest holds no more entropy than the data it
+	// was computed from; the last digest of a block may cover
+	// fewer bytes than the others.
+	credit := dc.Entropy
+	if dc.Whiten {
+		n := dc.BlockSize % MaxEventSize
+		if n == 0 {
+			n = MaxEventSize
+		}
+		credit = dc.Entropy * float64(n) / MaxEventSize
+	}
+
+	p := newDevicePoller(dc.Path, dc.BlockSize, dc.Whiten, dc.Entropy)
+	return r.Add(dc.Name, p, &PollerConfig{
+		Interval: dc.Interval,
+		Entropy:  credit,
+		Source:   dc.Source,
+	})
+}
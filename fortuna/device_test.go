@@ -0,0 +1,218 @@
+package fortuna
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDevicePoller(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	dev := filepath.Join(dir, "hwrng")
+
+	p := newDevicePoller(dev, 48, false, 0)
+	defer p.Close()
+	if _, err = p.Poll(context.Background()); err == nil {
+		fmt.Fprintf(os.Stderr, "fortuna: poll of a missing device should fail\n")
+		t.FailNow()
+	}
+
+	// The device appears, runs dry, disappears, and comes back.
+	ioutil.WriteFile(dev, randomEvent(64), 0644)
+	out, err := p.Poll(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if len(out) != 48 {
+		fmt.Fprintf(os.Stderr, "fortuna: read %d bytes from device, expected 48\n", len(out))
+		t.FailNow()
+	}
+
+	if _, err = p.Poll(context.Background()); err != ErrDeviceExhausted {
+		fmt.Fprintf(os.Stderr, "fortuna: short read from device should fail (%v)\n", err)
+		t.FailNow()
+	}
+
+	os.Remove(dev)
+	if _, err = p.Poll(context.Background()); err == nil {
+		fmt.Fprintf(os.Stderr, "fortuna: poll of a removed device should fail\n")
+		t.FailNow()
+	}
+
+	ioutil.WriteFile(dev, randomEvent(64), 0644)
+	if _, err = p.Poll(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "fortuna: device should be reopened (%v)\n", err)
+		t.FailNow()
+	}
+}
+
+func TestDeviceExhausted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	dev := filepath.Join(dir, "hwrng")
+	ioutil.WriteFile(dev, randomEvent(64), 0644)
+
+	// Polling past the end of a regular file must not read it
+	// again from the start; only data appended later is read.
+	p := newDevicePoller(dev, 32, false, 0)
+	defer p.Close()
+	var blocks [][]byte
+	for i := 0; i < 4; i++ {
+		out, err := p.Poll(context.Background())
+		if i >= 2 && err == ErrDeviceExhausted {
+			continue
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "fortuna: poll %d failed (%v)\n", i, err)
+			t.FailNow()
+		}
+		blocks = append(blocks, out)
+	}
+
+	f, err := os.OpenFile(dev, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	f.Write(randomEvent(32))
+	f.Close()
+	out, err := p.Poll(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fortuna: appended data was not read (%v)\n", err)
+		t.FailNow()
+	}
+	blocks = append(blocks, out)
+
+	if len(blocks) != 3 {
+		fmt.Fprintf(os.Stderr, "fortuna: read %d blocks, expected 3\n", len(blocks))
+		t.FailNow()
+	}
+	for i := range blocks {
+		for j := 0; j < i; j++ {
+			if bytes.Equal(blocks[i], blocks[j]) {
+				fmt.Fprintf(os.Stderr, "fortuna: block %d repeats block %d\n", i, j)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestDeviceWhiten(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	dev := filepath.Join(dir, "hwrng")
+
+	ioutil.WriteFile(dev, randomEvent(256), 0644)
+	p := newDevicePoller(dev, 100, true, 0)
+	defer p.Close()
+	out, err := p.Poll(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if len(out) != 4*MaxEventSize {
+		fmt.Fprintf(os.Stderr, "fortuna: whitened %d bytes, expected %d\n", len(out), 4*MaxEventSize)
+		t.FailNow()
+	}
+
+	// A stuck device fails the health tests on its raw output,
+	// even though its whitened output would look random.
+	ioutil.WriteFile(dev, make([]byte, 256), 0644)
+	p.Close()
+	if _, err = p.Poll(context.Background()); err != ErrHealthTest {
+		fmt.Fprintf(os.Stderr, "fortuna: stuck device should fail health tests (%v)\n", err)
+		t.FailNow()
+	}
+}
+
+func TestDeviceSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	dev := filepath.Join(dir, "hwrng")
+	ioutil.WriteFile(dev, randomEvent(64), 0644)
+
+	rng := New()
+	r := rng.StartPollers(context.Background(), nil)
+	defer r.Stop()
+	err = r.AddDevice(&DeviceSourceConfig{
+		Path:     dev,
+		Interval: time.Hour,
+		Entropy:  4,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	if ss := waitForEvents(t, rng, 1); ss.Name != "device:"+dev || ss.Entropy != 4*ss.Bytes {
+		fmt.Fprintf(os.Stderr, "fortuna: bad device source stats %+v\n", ss)
+		t.FailNow()
+	}
+}
+
+func TestDeviceCancel(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer w.Close()
+
+	// Stand in for a device that has stopped responding: the poll
+	// must return once its context is cancelled, and the block read
+	// later is returned by the next poll.
+	p := newDevicePoller("", 48, false, 0)
+	p.f = r
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err = p.Poll(ctx); err != context.DeadlineExceeded {
+		fmt.Fprintf(os.Stderr, "fortuna: stalled poll should be cancelled (%v)\n", err)
+		t.FailNow()
+	}
+
+	w.Write(randomEvent(48))
+	if out, err := p.Poll(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if len(out) != 48 {
+		fmt.Fprintf(os.Stderr, "fortuna: read %d bytes from device, expected 48\n", len(out))
+		t.FailNow()
+	}
+
+	// Closing the poller while a read is stalled doesn't wait for
+	// it; the device is closed once the read returns.
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	p.Poll(ctx)
+	p.Close()
+	w.Write(randomEvent(48))
+	for i := 0; i < 100; i++ {
+		if _, err = r.Stat(); errors.Is(err, os.ErrClosed) {
+			return
+		}
+		<-time.After(10 * time.Millisecond)
+	}
+	fmt.Fprintf(os.Stderr, "fortuna: device was not closed after the stalled read\n")
+	t.FailNow()
+}
@@ -1,8 +1,10 @@
 package fortuna
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 )
 
@@ -17,7 +19,10 @@ const (
 	newKeyBlocks int = 2 // len(rngKey) / aes.BlocksSize
 )
 
-var ErrReadTooLarge = errors.New("fortuna: can't provide requested number of bytes")
+var (
+	ErrReadTooLarge = errors.New("fortuna: can't provide requested number of bytes")
+	ErrSelfTest     = errors.New("fortuna: generator self-test failed")
+)
 
 // Generator represents the underlying PRG used by the Fortuna PRNG.
 type Generator struct {
@@ -143,3 +148,23 @@ func (g *Generator) Read(p []byte) (int, error) {
 	}
 	return pl, nil
 }
+
+// selfTestOutput is the expected output of a generator seeded with
+// "initial state" in the generator self-test.
+const selfTestOutput = "fcdfb28a3fb0a1527dca5c083fac33fd6c591974bdfaa1a7"
+
+// SelfTest runs a known-answer test on the generator, returning
+// ErrSelfTest if it does not produce the expected output.
+func SelfTest() error {
+	expected, _ := hex.DecodeString(selfTestOutput)
+	g := NewGenerator()
+	g.Reseed("initial state")
+
+	var p = make([]byte, len(expected))
+	if _, err := g.Read(p); err != nil {
+		return err
+	} else if !bytes.Equal(p, expected) {
+		return ErrSelfTest
+	}
+	return nil
+}
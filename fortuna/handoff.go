@@ -0,0 +1,48 @@
+package fortuna
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// SeedFDEnv names the environment variable used to tell a child
+// process which inherited file descriptor carries its seed. Only the
+// descriptor number is passed in the environment; the seed itself is
+// only ever written to the pipe.
+const SeedFDEnv = "FORTUNA_SEED_FD"
+
+// childSeedDomain separates seeds handed to child processes from
+// any other use of the parent's output.
+const childSeedDomain = "fortuna: child process seed"
+
+var (
+	ErrNoInheritedSeed    = errors.New("fortuna: no inherited seed")
+	ErrHandoffUnsupported = errors.New("fortuna: seed handoff is not supported on this platform")
+)
+
+// deriveSeed derives n bytes of key material from the PRNG for the
+// given domain. A fresh key is read from the PRNG on every call, so
+// the output is never reused.
+func (rng *Fortuna) deriveSeed(domain string, n int) ([]byte, error) {
+	var k = make([]byte, sha256.Size)
+	if _, err := io.ReadFull(rng, k); err != nil {
+		return nil, err
+	}
+	defer zero(k)
+
+	var ctr [4]byte
+	out := make([]byte, 0, n+sha256.Size)
+	for i := uint32(0); len(out) < n; i++ {
+		binary.BigEndian.PutUint32(ctr[:], i)
+		h := hmac.New(sha256.New, k)
+		h.Write([]byte(domain))
+		h.Write([]byte{0})
+		h.Write(ctr[:])
+		out = h.Sum(out)
+	}
+	zero(out[n:])
+	return out[:n], nil
+}
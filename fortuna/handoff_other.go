@@ -0,0 +1,20 @@
+//go:build !unix
+
+package fortuna
+
+import (
+	"os"
+	"os/exec"
+)
+
+// HandoffSeed returns ErrHandoffUnsupported on platforms where a
+// child process cannot inherit extra file descriptors.
+func (rng *Fortuna) HandoffSeed(cmd *exec.Cmd) (*os.File, error) {
+	return nil, ErrHandoffUnsupported
+}
+
+// FromInheritedSeed returns ErrHandoffUnsupported on platforms where
+// a child process cannot inherit extra file descriptors.
+func FromInheritedSeed() (*Fortuna, error) {
+	return nil, ErrHandoffUnsupported
+}
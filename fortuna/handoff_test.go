@@ -0,0 +1,34 @@
+package fortuna
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestDeriveSeed(t *testing.T) {
+	rng := seededRNG(t)
+	a, err := rng.deriveSeed(childSeedDomain, SeedFileLength)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	b, err := rng.deriveSeed(childSeedDomain, SeedFileLength)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if len(a) != SeedFileLength || len(b) != SeedFileLength {
+		fmt.Fprintf(os.Stderr, "fortuna: bad derived seed length\n")
+		t.FailNow()
+	} else if bytes.Equal(a, b) {
+		fmt.Fprintf(os.Stderr, "fortuna: derived seeds should never repeat\n")
+		t.FailNow()
+	}
+
+	if _, err = New().deriveSeed(childSeedDomain, SeedFileLength); err != ErrNotSeeded {
+		fmt.Fprintf(os.Stderr, "fortuna: unseeded PRNG should not derive seeds\n")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,95 @@
+//go:build unix
+
+package fortuna
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// HandoffSeed derives a one-time seed for a child process and
+// arranges for cmd to inherit it over a pipe. It must be called
+// before cmd is started. The returned file is the parent's copy of
+// the read end of the pipe; the caller should close it once the
+// child has started. The child should call FromInheritedSeed to
+// start its own PRNG.
+func (rng *Fortuna) HandoffSeed(cmd *exec.Cmd) (*os.File, error) {
+	if !rng.Initialised() {
+		return nil, ErrNotInitialised
+	}
+
+	seed, err := rng.deriveSeed(childSeedDomain, SeedFileLength)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(seed)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	// The seed is much smaller than the pipe buffer, so this does
+	// not block waiting for the child.
+	_, err = w.Write(seed)
+	w.Close()
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	cmd.ExtraFiles = append(cmd.ExtraFiles, r)
+	fd := 2 + len(cmd.ExtraFiles)
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = make([]string, 0, len(env)+1)
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, SeedFDEnv+"=") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", SeedFDEnv, fd))
+	return r, nil
+}
+
+// FromInheritedSeed creates a new PRNG from a seed handed off by the
+// parent process with HandoffSeed. The inherited descriptor is
+// closed and the environment variable naming it is removed, so the
+// seed cannot be read a second time.
+func FromInheritedSeed() (*Fortuna, error) {
+	v := os.Getenv(SeedFDEnv)
+	if v == "" {
+		return nil, ErrNoInheritedSeed
+	}
+	os.Unsetenv(SeedFDEnv)
+
+	fd, err := strconv.Atoi(v)
+	if err != nil || fd < 0 {
+		return nil, ErrNoInheritedSeed
+	}
+
+	f := os.NewFile(uintptr(fd), "fortuna-seed")
+	if f == nil {
+		return nil, ErrNoInheritedSeed
+	}
+	defer f.Close()
+
+	var seed = make([]byte, SeedFileLength)
+	defer zero(seed)
+	if _, err = io.ReadFull(f, seed); err != nil {
+		return nil, ErrInvalidSeed
+	}
+
+	rng := New()
+	if err = rng.ReadSeed(seed); err != nil {
+		return nil, err
+	}
+	return rng, nil
+}
@@ -0,0 +1,92 @@
+//go:build unix
+
+package fortuna
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestInheritedSeed(t *testing.T) {
+	if _, err := FromInheritedSeed(); err != ErrNoInheritedSeed {
+		fmt.Fprintf(os.Stderr, "fortuna: expected no inherited seed\n")
+		t.FailNow()
+	}
+
+	rng := seededRNG(t)
+	cmd := exec.Command("true")
+	cmd.Env = []string{SeedFDEnv + "=99", "PATH=/bin"}
+	r, err := rng.HandoffSeed(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	for _, arg := range cmd.Args {
+		if strings.Contains(arg, SeedFDEnv) {
+			fmt.Fprintf(os.Stderr, "fortuna: seed handoff leaked into argv\n")
+			t.FailNow()
+		}
+	}
+	if len(cmd.Env) != 2 || cmd.Env[1] != SeedFDEnv+"=3" {
+		fmt.Fprintf(os.Stderr, "fortuna: bad child environment %v\n", cmd.Env)
+		t.FailNow()
+	}
+
+	// Stand in for the child by pointing the environment at a
+	// duplicate of the parent's copy of the read end.
+	fd, err := syscall.Dup(int(r.Fd()))
+	r.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	os.Setenv(SeedFDEnv, fmt.Sprintf("%d", fd))
+	child, err := FromInheritedSeed()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if os.Getenv(SeedFDEnv) != "" {
+		fmt.Fprintf(os.Stderr, "fortuna: inherited seed variable was not cleared\n")
+		t.FailNow()
+	}
+
+	var p = make([]byte, 32)
+	if _, err = child.Read(p); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+}
+
+func TestHandoffSeedProcess(t *testing.T) {
+	if os.Getenv("FORTUNA_TEST_CHILD") == "1" {
+		if _, err := FromInheritedSeed(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	rng := seededRNG(t)
+	cmd := exec.Command(os.Args[0], "-test.run=TestHandoffSeedProcess")
+	cmd.Env = append(os.Environ(), "FORTUNA_TEST_CHILD=1")
+	r, err := rng.HandoffSeed(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	err = cmd.Start()
+	r.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if err = cmd.Wait(); err != nil {
+		fmt.Fprintf(os.Stderr, "fortuna: child failed to start from inherited seed (%v)\n", err)
+		t.FailNow()
+	}
+}
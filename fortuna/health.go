@@ -0,0 +1,141 @@
+package fortuna
+
+import (
+	"fmt"
+	"time"
+)
+
+// HealthStatus is the overall verdict of a health check.
+type HealthStatus int
+
+const (
+	// HealthOK indicates that the PRNG is seeded and operating
+	// normally.
+	HealthOK HealthStatus = iota
+
+	// HealthDegraded indicates that the PRNG can provide random
+	// data, but that something needs attention, such as a silent
+	// source or a failing seed file.
+	HealthDegraded
+
+	// HealthFailed indicates that the PRNG cannot be relied upon
+	// to provide random data.
+	HealthFailed
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case HealthOK:
+		return "ok"
+	case HealthDegraded:
+		return "degraded"
+	case HealthFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// HealthConfig sets the thresholds used by Health. A zero field
+// disables that check.
+type HealthConfig struct {
+	// MaxReseedInterval is the longest the PRNG may go without
+	// reseeding from its pools before it is reported as degraded.
+	MaxReseedInterval time.Duration
+
+	// MaxSourceSilence is the longest a registered source may go
+	// without delivering an event before the PRNG is reported as
+	// degraded.
+	MaxSourceSilence time.Duration
+}
+
+// Health is the result of a health check.
+type Health struct {
+	Status  HealthStatus
+	Reasons []string
+}
+
+// SetHealthConfig sets the thresholds used by Health.
+func (rng *Fortuna) SetHealthConfig(cfg HealthConfig) {
+	rng.mu.Lock()
+	rng.health = cfg
+	rng.mu.Unlock()
+}
+
+func (h *Health) fail(reason string) {
+	h.Status = HealthFailed
+	h.Reasons = append(h.Reasons, reason)
+}
+
+func (h *Health) degrade(reason string) {
+	if h.Status < HealthDegraded {
+		h.Status = HealthDegraded
+	}
+	h.Reasons = append(h.Reasons, reason)
+}
+
+// Health evaluates the state of the PRNG, returning a single verdict
+// along with the reasons for it, suitable for readiness checks and
+// alerting. It is computed from the same state reported by Stats.
+func (rng *Fortuna) Health() *Health {
+	h := &Health{}
+	if !rng.Initialised() {
+		h.fail("not initialised")
+		return h
+	}
+
+	st := rng.Stats()
+	now := time.Now()
+
+	rng.mu.Lock()
+	stale := rng.stale
+	created := rng.created
+	selfTest := rng.selfTest
+	saveErr := rng.saveErr
+	cfg := rng.health
+	policy := rng.policy
+	rng.mu.Unlock()
+
+	if selfTest != nil {
+		h.fail(fmt.Sprintf("self-test failed: %v", selfTest))
+	}
+	if st.Reseeds == 0 {
+		h.fail("not seeded")
+	}
+	if !st.Ready && policy.MinSources > 0 && st.ReadySources < policy.MinSources {
+		h.fail(fmt.Sprintf("waiting for sources: have %d of %d",
+			st.ReadySources, policy.MinSources))
+	}
+	if stale {
+		h.fail("state may have been duplicated; awaiting fresh reseed")
+	}
+
+	if saveErr != nil {
+		h.degrade(fmt.Sprintf("saving seed file failed: %v", saveErr))
+	}
+
+	if cfg.MaxReseedInterval > 0 {
+		last := st.LastReseed
+		if last.Before(created) {
+			last = created
+		}
+		if d := now.Sub(last); d > cfg.MaxReseedInterval {
+			h.degrade(fmt.Sprintf("no reseed for %s", d))
+		}
+	}
+
+	for _, ss := range st.Sources {
+		if ss.Quarantined {
+			h.degrade(fmt.Sprintf("source %d (%s) quarantined after failing health tests",
+				ss.ID, ss.Name))
+		}
+		if ss.Name == "" {
+			continue
+		}
+
+		d := ss.idle(now)
+		if ss.Silent || (cfg.MaxSourceSilence > 0 && d > cfg.MaxSourceSilence) {
+			h.degrade(fmt.Sprintf("source %s silent for %s", ss.Name, d))
+		}
+	}
+	return h
+}
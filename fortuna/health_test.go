@@ -0,0 +1,79 @@
+package fortuna
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSelfTest(t *testing.T) {
+	if err := SelfTest(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+}
+
+func TestHealth(t *testing.T) {
+	if h := (&Fortuna{}).Health(); h.Status != HealthFailed {
+		fmt.Fprintf(os.Stderr, "fortuna: uninitialised PRNG should fail health check\n")
+		t.FailNow()
+	}
+
+	rng := New()
+	if h := rng.Health(); h.Status != HealthFailed || len(h.Reasons) != 1 {
+		fmt.Fprintf(os.Stderr, "fortuna: unseeded PRNG should fail health check %+v\n", h)
+		t.FailNow()
+	}
+
+	rng = seededRNG(t)
+	if h := rng.Health(); h.Status != HealthOK {
+		fmt.Fprintf(os.Stderr, "fortuna: seeded PRNG should be healthy %+v\n", h)
+		t.FailNow()
+	}
+
+	if err := rng.WriteSeed(filepath.Join("nonexistent", "test.seed")); err == nil {
+		fmt.Fprintf(os.Stderr, "fortuna: seed file write should fail\n")
+		t.FailNow()
+	} else if h := rng.Health(); h.Status != HealthDegraded {
+		fmt.Fprintf(os.Stderr, "fortuna: failing seed save should degrade health %+v\n", h)
+		t.FailNow()
+	}
+
+	rng.invalidate()
+	if h := rng.Health(); h.Status != HealthFailed || len(h.Reasons) != 2 {
+		fmt.Fprintf(os.Stderr, "fortuna: stale PRNG should fail health check %+v\n", h)
+		t.FailNow()
+	}
+}
+
+func TestHealthTimeouts(t *testing.T) {
+	rng := seededRNG(t)
+	src, err := rng.RegisterSource("quiet")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	rng.SetHealthConfig(HealthConfig{
+		MaxReseedInterval: 10 * time.Millisecond,
+		MaxSourceSilence:  10 * time.Millisecond,
+	})
+	if h := rng.Health(); h.Status != HealthOK {
+		fmt.Fprintf(os.Stderr, "fortuna: PRNG should be healthy %+v\n", h)
+		t.FailNow()
+	}
+
+	<-time.After(20 * time.Millisecond)
+	if h := rng.Health(); h.Status != HealthDegraded || len(h.Reasons) != 2 {
+		fmt.Fprintf(os.Stderr, "fortuna: expected reseed and source warnings %+v\n", h)
+		t.FailNow()
+	}
+
+	src.Add([]byte{1})
+	if h := rng.Health(); h.Status != HealthDegraded || len(h.Reasons) != 1 {
+		fmt.Fprintf(os.Stderr, "fortuna: source should no longer be silent %+v\n", h)
+		t.FailNow()
+	}
+}
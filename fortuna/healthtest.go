@@ -0,0 +1,228 @@
+package fortuna
+
+import (
+	"errors"
+	"math"
+)
+
+// HealthTestEntropy is the min-entropy per byte, in bits, assumed
+// when setting the cutoffs of a registered source's health tests if it
+// was not registered with its own estimate. Lower values make the
+// tests more tolerant of structured events.
+var HealthTestEntropy = 1.0
+
+// UnregisteredHealthTestEntropy is the min-entropy per byte, in bits,
+// assumed for source identifiers used with AddRandomEvent, unless one
+// is set with SetHealthTestEntropy. It is lower than HealthTestEntropy
+// because such sources, like the keypress timings the package
+// documentation suggests, are often structured: a little-endian
+// timestamp delta has mostly zero high bytes.
+var UnregisteredHealthTestEntropy = 0.5
+
+// ProbationSamples is the number of consecutive bytes a quarantined
+// source must deliver without a health test failure before it is
+// returned to service.
+var ProbationSamples = 2 * aptWindow
+
+// aptWindow is the window size of the Adaptive Proportion Test for
+// non-binary samples.
+const aptWindow = 512
+
+// healthTestAlpha is the false positive probability of the health
+// tests, 2^-20, as recommended by SP 800-90B.
+const healthTestAlpha = 1.0 / (1 << 20)
+
+var (
+	ErrHealthTest  = errors.New("fortuna: source failed health test")
+	ErrQuarantined = errors.New("fortuna: source quarantined")
+)
+
+// rctCutoff returns the cutoff for the Repetition Count Test for the
+// given min-entropy per sample (SP 800-90B, section 4.4.1).
+func rctCutoff(h float64) int {
+	return 1 + int(math.Ceil(-math.Log2(healthTestAlpha)/h))
+}
+
+// aptCutoff returns the cutoff for the Adaptive Proportion Test for
+// the given min-entropy per sample: the smallest count that the most
+// likely sample value reaches within a window with probability at
+// most alpha (SP 800-90B, section 4.4.2).
+func aptCutoff(h float64) int {
+	p := math.Pow(2, -h)
+	lp, lq := math.Log(p), math.Log1p(-p)
+	lgn, _ := math.Lgamma(aptWindow + 1)
+
+	var tail float64
+	for k := aptWindow; k > 0; k-- {
+		lgk, _ := math.Lgamma(float64(k + 1))
+		lgnk, _ := math.Lgamma(float64(aptWindow - k + 1))
+		tail += math.Exp(lgn - lgk - lgnk + float64(k)*lp + float64(aptWindow-k)*lq)
+		if tail > healthTestAlpha {
+			return k + 1
+		}
+	}
+	return 1
+}
+
+// healthTest runs the SP 800-90B Repetition Count Test and Adaptive
+// Proportion Test over a stream of byte samples.
+type healthTest struct {
+	rctCutoff int
+	aptCutoff int
+
+	started bool
+	last    byte
+	run     int
+
+	aptFirst byte
+	aptCount int
+	aptSeen  int
+}
+
+func newHealthTest(h float64) *healthTest {
+	if h <= 0 || h > 8 {
+		h = HealthTestEntropy
+	}
+	return &healthTest{
+		rctCutoff: rctCutoff(h),
+		aptCutoff: aptCutoff(h),
+	}
+}
+
+// sample runs both tests on the next sample, returning false if
+// either test fails.
+func (t *healthTest) sample(b byte) bool {
+	ok := true
+	if t.started && b == t.last {
+		t.run++
+		if t.run >= t.rctCutoff {
+			ok = false
+			t.run = 1
+		}
+	} else {
+		t.last = b
+		t.run = 1
+	}
+	t.started = true
+
+	if t.aptSeen == 0 {
+		t.aptFirst = b
+		t.aptCount = 1
+		t.aptSeen = 1
+		return ok
+	}
+
+	t.aptSeen++
+	if b == t.aptFirst {
+		t.aptCount++
+		if t.aptCount >= t.aptCutoff {
+			ok = false
+			t.aptCount = 0
+		}
+	}
+	if t.aptSeen >= aptWindow {
+		t.aptSeen = 0
+	}
+	return ok
+}
+
+// sourceHealth tracks the health test state of a source identifier.
+// It is guarded by the PRNG's source lock.
+type sourceHealth struct {
+	test        *healthTest
+	quarantined bool
+	probation   int
+	failures    uint64
+}
+
+// screen runs the health tests for source s over an event. It
+// returns ErrHealthTest if the event causes the source to be
+// quarantined, ErrQuarantined if the source is already quarantined,
+// and nil if the event may be added to the pools. A quarantined
+// source's events are still tested, and the source is returned to
+// service once it has passed ProbationSamples samples in a row.
+func (rng *Fortuna) screen(s byte, e []byte) error {
+	var ev *Event
+	var err error
+
+	rng.srcLock.Lock()
+	sh := rng.healthState(s)
+	wasQuarantined := sh.quarantined
+	for _, b := range e {
+		if !sh.test.sample(b) {
+			sh.failures++
+			sh.quarantined = true
+			sh.probation = 0
+			continue
+		}
+		if sh.quarantined {
+			sh.probation++
+			if sh.probation >= ProbationSamples {
+				sh.quarantined = false
+			}
+		}
+	}
+
+	switch {
+	case !wasQuarantined && sh.quarantined:
+		err = ErrHealthTest
+		ev = &Event{Kind: EventSourceQuarantined}
+	case wasQuarantined && !sh.quarantined:
+		// The probation window ended part way through this
+		// event; drop it, and accept the next.
+		err = ErrQuarantined
+		ev = &Event{Kind: EventSourceReleased}
+	case sh.quarantined:
+		err = ErrQuarantined
+	}
+
+	if ev != nil {
+		ev.SourceID = s
+		if src := rng.ids[s]; src != nil {
+			ev.Source = src.name
+		}
+	}
+	rng.srcLock.Unlock()
+
+	if ev != nil {
+		rng.emit(*ev)
+	}
+	return err
+}
+
+// healthState returns the health test state for source s, creating
+// it if needed. The source lock must be held.
+func (rng *Fortuna) healthState(s byte) *sourceHealth {
+	if rng.screens[s] == nil {
+		h := UnregisteredHealthTestEntropy
+		if src := rng.ids[s]; src != nil {
+			h = HealthTestEntropy
+			if src.opts.HealthTestEntropy > 0 {
+				h = src.opts.HealthTestEntropy
+			}
+		}
+		rng.screens[s] = &sourceHealth{test: newHealthTest(h)}
+	}
+	return rng.screens[s]
+}
+
+// SetHealthTestEntropy sets the min-entropy per byte, in bits, used
+// for the health tests of a source identifier used with
+// AddRandomEvent, in place of UnregisteredHealthTestEntropy. The
+// tests are restarted with the new cutoffs, but a quarantined source
+// remains quarantined. Registered sources set their estimate with
+// the HealthTestEntropy option; their identifiers return
+// ErrSourceRegistered.
+func (rng *Fortuna) SetHealthTestEntropy(s byte, h float64) error {
+	if h <= 0 || h > 8 {
+		return ErrInvalidEvent
+	}
+	if err := rng.claim(s); err != nil {
+		return err
+	}
+
+	rng.srcLock.Lock()
+	defer rng.srcLock.Unlock()
+	rng.healthState(s).test = newHealthTest(h)
+	return nil
+}
@@ -0,0 +1,179 @@
+package fortuna
+
+import (
+	"encoding/binary"
+	"fmt"
+	mrand "math/rand"
+	"os"
+	"testing"
+)
+
+func TestHealthTestCutoffs(t *testing.T) {
+	// Cutoffs for a window of 512 samples from SP 800-90B.
+	var expected = []struct {
+		h   float64
+		rct int
+		apt int
+	}{
+		{0.5, 41, 410},
+		{1, 21, 311},
+		{2, 11, 177},
+		{4, 6, 62},
+		{8, 4, 13},
+	}
+
+	for _, c := range expected {
+		if n := rctCutoff(c.h); n != c.rct {
+			fmt.Fprintf(os.Stderr, "fortuna: RCT cutoff for H=%v is %d, expected %d\n", c.h, n, c.rct)
+			t.FailNow()
+		} else if n = aptCutoff(c.h); n != c.apt {
+			fmt.Fprintf(os.Stderr, "fortuna: APT cutoff for H=%v is %d, expected %d\n", c.h, n, c.apt)
+			t.FailNow()
+		}
+	}
+}
+
+func TestHealthTestAPT(t *testing.T) {
+	// Alternating values never trip the repetition count test,
+	// but one value makes up half the window.
+	ht := newHealthTest(2)
+	for i := 0; i < aptWindow; i++ {
+		b := byte(i)
+		if i%2 == 0 {
+			b = 0
+		}
+		if !ht.sample(b) {
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "fortuna: adaptive proportion test should have failed\n")
+	t.FailNow()
+}
+
+func TestQuarantine(t *testing.T) {
+	rng := New()
+	events := make(chan Event, ObserverQueueSize)
+	remove := rng.AddObserver(ObserverFunc(func(ev Event) {
+		if ev.Kind != EventSourceError {
+			events <- ev
+		}
+	}))
+	defer remove()
+
+	src, err := rng.RegisterSource("stuck")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	// A stuck source returning zeros fails the repetition count
+	// test on its first event.
+	if err = src.Add(make([]byte, MaxEventSize)); err != ErrHealthTest {
+		fmt.Fprintf(os.Stderr, "fortuna: stuck source should fail health test (%v)\n", err)
+		t.FailNow()
+	} else if err = src.Add(randomEvent(MaxEventSize)); err != ErrQuarantined {
+		fmt.Fprintf(os.Stderr, "fortuna: quarantined source should be dropped (%v)\n", err)
+		t.FailNow()
+	}
+
+	if ev := nextEvent(t, events); ev.Kind != EventSourceQuarantined || ev.Source != "stuck" {
+		fmt.Fprintf(os.Stderr, "fortuna: expected quarantine event, got %+v\n", ev)
+		t.FailNow()
+	}
+
+	st := rng.Stats()
+	if !st.Sources[0].Quarantined || st.Sources[0].HealthFailures == 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: stats should report the quarantine %+v\n", st.Sources[0])
+		t.FailNow()
+	} else if st.Sources[0].Events != 0 || st.Pools[0] != 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: quarantined events reached the pools\n")
+		t.FailNow()
+	} else if h := rng.Health(); h.Status < HealthDegraded {
+		fmt.Fprintf(os.Stderr, "fortuna: quarantine should degrade health\n")
+		t.FailNow()
+	}
+
+	for i := 0; i < ProbationSamples/MaxEventSize; i++ {
+		src.Add(randomEvent(MaxEventSize))
+	}
+	if ev := nextEvent(t, events); ev.Kind != EventSourceReleased {
+		fmt.Fprintf(os.Stderr, "fortuna: expected release event, got %+v\n", ev)
+		t.FailNow()
+	} else if err = src.Add(randomEvent(MaxEventSize)); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+}
+
+func TestQuarantineRawSource(t *testing.T) {
+	rng := New()
+	sw := NewSourceWriter(rng, 7)
+	if _, err := sw.Write(make([]byte, 4*MaxEventSize)); err != ErrHealthTest {
+		fmt.Fprintf(os.Stderr, "fortuna: zeros written to a source writer should be rejected\n")
+		t.FailNow()
+	} else if err = rng.AddRandomEvent(7, 0, randomEvent(MaxEventSize)); err != ErrQuarantined {
+		fmt.Fprintf(os.Stderr, "fortuna: quarantine should apply to AddRandomEvent\n")
+		t.FailNow()
+	}
+}
+
+// timestampEvents returns n events holding little-endian timestamp
+// deltas of between 65us and 16ms, whose high bytes are always zero.
+func timestampEvents(n int) [][]byte {
+	r := mrand.New(mrand.NewSource(1))
+	var events = make([][]byte, n)
+	for i := range events {
+		events[i] = make([]byte, 8)
+		binary.LittleEndian.PutUint64(events[i], uint64(1<<16+r.Int63n(1<<24-1<<16)))
+	}
+	return events
+}
+
+func TestTimestampEvents(t *testing.T) {
+	// Structured events from an unregistered source, such as
+	// keypress timings, are not quarantined. A short first event
+	// moves the start of each APT window onto a zero high byte.
+	rng := New()
+	if err := rng.SetHealthTestEntropy(4, 1); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	for _, s := range []byte{3, 4} {
+		if err := rng.AddRandomEvent(s, 0, []byte{1, 2, 3}); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			t.FailNow()
+		}
+	}
+	for i, e := range timestampEvents(2000) {
+		if err := rng.AddRandomEvent(3, i%PoolSize, e); err != nil {
+			fmt.Fprintf(os.Stderr, "fortuna: timestamp event %d was rejected (%v)\n", i, err)
+			t.FailNow()
+		}
+	}
+
+	// The same events fail the tests for a source claiming a bit
+	// of entropy per byte.
+	var failed bool
+	for i, e := range timestampEvents(2000) {
+		if rng.AddRandomEvent(4, i%PoolSize, e) == ErrHealthTest {
+			failed = true
+			break
+		}
+	}
+	if !failed {
+		fmt.Fprintf(os.Stderr, "fortuna: health test estimate was not applied\n")
+		t.FailNow()
+	}
+
+	src, err := rng.RegisterSource("hw")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if err = rng.SetHealthTestEntropy(src.ID(), 1); err != ErrSourceRegistered {
+		fmt.Fprintf(os.Stderr, "fortuna: registered source estimate should be rejected (%v)\n", err)
+		t.FailNow()
+	} else if err = rng.SetHealthTestEntropy(5, 0); err != ErrInvalidEvent {
+		fmt.Fprintf(os.Stderr, "fortuna: invalid estimate should be rejected (%v)\n", err)
+		t.FailNow()
+	}
+}
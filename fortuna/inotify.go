@@ -0,0 +1,94 @@
+package fortuna
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+)
+
+// FileWatchSourceName is the default name the filesystem activity
+// source is registered under.
+const FileWatchSourceName = "inotify"
+
+var (
+	ErrWatchUnsupported = errors.New("fortuna: filesystem watching is not supported on this platform")
+	ErrWatchLimit       = errors.New("fortuna: filesystem watch limit reached")
+)
+
+// FileWatchConfig controls a FileWatcher.
+type FileWatchConfig struct {
+	// Paths lists the directories to watch.
+	Paths []string
+
+	// Recursive, if true, also watches every directory below
+	// each path, including directories created later.
+	Recursive bool
+
+	// MaxWatches limits the number of directories watched; if
+	// zero, only the kernel's limit applies. Once the limit is
+	// reached, further directories are not watched, and Err
+	// returns ErrWatchLimit.
+	MaxWatches int
+
+	// Name is the name the source is registered under. If empty,
+	// FileWatchSourceName is used.
+	Name string
+
+	// Source configures the registered source. On busy hosts, a
+	// rate limit keeps filesystem churn from dominating the
+	// pools.
+	Source *SourceOptions
+}
+
+// FileWatcher turns filesystem activity in a set of directories into
+// events: the timing and metadata of each change (the watch, event
+// mask, cookie, and a hash of the file name) are hashed into an event
+// for each batch of changes read from the kernel. It uses Linux
+// inotify, and is started with WatchFiles.
+type FileWatcher struct {
+	rng    *Fortuna
+	src    *Source
+	cfg    FileWatchConfig
+	fd     int
+	f      *os.File
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	lock  sync.Mutex
+	paths map[int32]string
+	err   error
+}
+
+// Watches returns the number of directories being watched.
+func (w *FileWatcher) Watches() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return len(w.paths)
+}
+
+// Err returns the last error encountered while adding watches or
+// reading events, if any.
+func (w *FileWatcher) Err() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.err
+}
+
+func (w *FileWatcher) setErr(err error) {
+	w.lock.Lock()
+	w.err = err
+	w.lock.Unlock()
+}
+
+// Done returns a channel that is closed once the watcher has stopped.
+func (w *FileWatcher) Done() <-chan struct{} {
+	return w.done
+}
+
+// Stop halts the watcher, waiting for it to exit and closing its
+// source.
+func (w *FileWatcher) Stop() {
+	w.cancel()
+	<-w.done
+}
@@ -0,0 +1,194 @@
+package fortuna
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// fileWatchMask selects the changes reported for each watched
+// directory.
+const fileWatchMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY |
+	syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_FROM |
+	syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF
+
+// WatchFiles starts a FileWatcher on the directories in cfg, and
+// registers its source. The watcher stops when ctx is cancelled or
+// its Stop method is called.
+func (rng *Fortuna) WatchFiles(ctx context.Context, cfg *FileWatchConfig) (*FileWatcher, error) {
+	w := &FileWatcher{
+		rng:   rng,
+		done:  make(chan struct{}),
+		paths: map[int32]string{},
+	}
+	if cfg != nil {
+		w.cfg = *cfg
+	}
+	if w.cfg.Name == "" {
+		w.cfg.Name = FileWatchSourceName
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	// As the descriptor is non-blocking, reads from the file use
+	// the runtime poller and are interrupted when it is closed.
+	// Calling its Fd method would make it blocking, so the
+	// descriptor is kept for adding watches.
+	w.fd = fd
+	w.f = os.NewFile(uintptr(fd), "inotify")
+
+	for _, path := range w.cfg.Paths {
+		if err = w.watch(path); err != nil && err != ErrWatchLimit {
+			w.f.Close()
+			return nil, err
+		}
+	}
+
+	w.src, err = rng.RegisterSourceWithOptions(w.cfg.Name, w.cfg.Source)
+	if err != nil {
+		w.f.Close()
+		return nil, err
+	}
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	go func() {
+		<-ctx.Done()
+		w.f.Close()
+	}()
+	go w.run()
+	return w, nil
+}
+
+// watch adds a watch for a directory and, if the watcher is
+// recursive, for the directories below it. Directories that vanish
+// while they are being walked are skipped.
+func (w *FileWatcher) watch(path string) error {
+	if !w.cfg.Recursive {
+		return w.addWatch(path)
+	}
+
+	return filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p != path {
+				return nil
+			}
+			return err
+		}
+		if !fi.IsDir() {
+			return nil
+		}
+		return w.addWatch(p)
+	})
+}
+
+func (w *FileWatcher) addWatch(path string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.cfg.MaxWatches > 0 && len(w.paths) >= w.cfg.MaxWatches {
+		w.err = ErrWatchLimit
+		return ErrWatchLimit
+	}
+
+	wd, err := syscall.InotifyAddWatch(w.fd, path, fileWatchMask)
+	if err == syscall.ENOSPC {
+		// The kernel's max_user_watches has been reached.
+		w.err = ErrWatchLimit
+		return ErrWatchLimit
+	} else if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: path, Err: err}
+	}
+	w.paths[int32(wd)] = path
+	return nil
+}
+
+func (w *FileWatcher) run() {
+	defer close(w.done)
+	defer w.src.Close()
+
+	var buf = make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		now := time.Now()
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.setErr(err)
+			}
+			return
+		}
+
+		w.src.Add(w.digest(buf[:n], now))
+	}
+}
+
+// digest hashes a batch of inotify events with the time they were
+// read, watching any new directories in a recursive watcher and
+// forgetting watches that the kernel has removed.
+func (w *FileWatcher) digest(p []byte, now time.Time) []byte {
+	var rec [28]byte
+	h := sha256.New()
+	binary.LittleEndian.PutUint64(rec[:8], uint64(now.UnixNano()))
+	h.Write(rec[:8])
+
+	for len(p) >= syscall.SizeofInotifyEvent {
+		wd := int32(binary.LittleEndian.Uint32(p[0:]))
+		mask := binary.LittleEndian.Uint32(p[4:])
+		cookie := binary.LittleEndian.Uint32(p[8:])
+		size := int(binary.LittleEndian.Uint32(p[12:]))
+		p = p[syscall.SizeofInotifyEvent:]
+		if size > len(p) {
+			size = len(p)
+		}
+		name := trimName(p[:size])
+		p = p[size:]
+
+		nameSum := sha256.Sum256(name)
+		binary.LittleEndian.PutUint64(rec[0:], uint64(time.Since(now)))
+		binary.LittleEndian.PutUint32(rec[8:], uint32(wd))
+		binary.LittleEndian.PutUint32(rec[12:], mask)
+		binary.LittleEndian.PutUint32(rec[16:], cookie)
+		copy(rec[20:], nameSum[:8])
+		h.Write(rec[:])
+
+		w.update(wd, mask, string(name))
+	}
+	return h.Sum(nil)
+}
+
+// trimName removes the NUL padding from an event's name.
+func trimName(name []byte) []byte {
+	for i, b := range name {
+		if b == 0 {
+			return name[:i]
+		}
+	}
+	return name
+}
+
+func (w *FileWatcher) update(wd int32, mask uint32, name string) {
+	if mask&syscall.IN_IGNORED != 0 {
+		w.lock.Lock()
+		delete(w.paths, wd)
+		w.lock.Unlock()
+		return
+	}
+
+	if !w.cfg.Recursive || mask&syscall.IN_CREATE == 0 || mask&syscall.IN_ISDIR == 0 {
+		return
+	}
+
+	w.lock.Lock()
+	parent, ok := w.paths[wd]
+	w.lock.Unlock()
+	if ok {
+		if err := w.watch(filepath.Join(parent, name)); err != nil {
+			w.setErr(err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package fortuna
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+
+	rng := New()
+	w, err := rng.WatchFiles(context.Background(), &FileWatchConfig{
+		Paths:     []string{dir},
+		Recursive: true,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if n := w.Watches(); n != 2 {
+		fmt.Fprintf(os.Stderr, "fortuna: watching %d directories, expected 2\n", n)
+		t.FailNow()
+	}
+
+	// New directories are watched as they are created.
+	os.Mkdir(filepath.Join(dir, "new"), 0755)
+	for i := 0; i < 100 && w.Watches() != 3; i++ {
+		<-time.After(10 * time.Millisecond)
+	}
+	if n := w.Watches(); n != 3 {
+		fmt.Fprintf(os.Stderr, "fortuna: new directory was not watched (%d watches)\n", n)
+		t.FailNow()
+	}
+
+	for i := 0; i < 8; i++ {
+		ioutil.WriteFile(filepath.Join(dir, "new", fmt.Sprintf("file%d", i)), []byte("x"), 0644)
+	}
+	if ss := waitForEvents(t, rng, 2); ss.Name != FileWatchSourceName {
+		fmt.Fprintf(os.Stderr, "fortuna: filesystem activity was not added %+v\n", ss)
+		t.FailNow()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		w.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		fmt.Fprintf(os.Stderr, "fortuna: watcher did not stop\n")
+		t.FailNow()
+	}
+	if err = w.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if _, err = rng.RegisterSource(FileWatchSourceName); err != nil {
+		fmt.Fprintf(os.Stderr, "fortuna: stopped watcher should release its source (%v)\n", err)
+		t.FailNow()
+	}
+}
+
+func TestWatchFilesLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	for i := 0; i < 4; i++ {
+		os.Mkdir(filepath.Join(dir, fmt.Sprintf("dir%d", i)), 0755)
+	}
+
+	rng := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err := rng.WatchFiles(ctx, &FileWatchConfig{
+		Paths:      []string{dir},
+		Recursive:  true,
+		MaxWatches: 3,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if w.Watches() != 3 || w.Err() != ErrWatchLimit {
+		fmt.Fprintf(os.Stderr, "fortuna: watch limit was not applied (%d, %v)\n", w.Watches(), w.Err())
+		t.FailNow()
+	}
+
+	cancel()
+	select {
+	case <-w.Done():
+	case <-time.After(time.Second):
+		fmt.Fprintf(os.Stderr, "fortuna: watcher did not stop on cancel\n")
+		t.FailNow()
+	}
+
+	if _, err = rng.WatchFiles(context.Background(), &FileWatchConfig{
+		Paths: []string{filepath.Join(dir, "missing")},
+	}); err == nil {
+		fmt.Fprintf(os.Stderr, "fortuna: watching a missing directory should fail\n")
+		t.FailNow()
+	}
+}
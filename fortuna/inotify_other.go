@@ -0,0 +1,11 @@
+//go:build !linux
+
+package fortuna
+
+import "context"
+
+// WatchFiles requires Linux inotify; on other platforms it returns
+// ErrWatchUnsupported.
+func (rng *Fortuna) WatchFiles(ctx context.Context, cfg *FileWatchConfig) (*FileWatcher, error) {
+	return nil, ErrWatchUnsupported
+}
@@ -0,0 +1,267 @@
+package fortuna
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+// JitterSourceName is the name the CPU jitter source is registered
+// under.
+const JitterSourceName = "jitter"
+
+// JitterOversampling is the default number of timing samples
+// collected for each bit of output.
+const JitterOversampling = 3
+
+// JitterInterval is the default interval between polls of the CPU
+// jitter source.
+const JitterInterval = 10 * time.Second
+
+const (
+	// jitterMemorySize, jitterStride, and jitterAccesses control
+	// the memory access loop: each sample touches jitterAccesses
+	// bytes, jitterStride apart, in a buffer large enough to miss
+	// the first level caches.
+	jitterMemorySize = 64 << 10
+	jitterStride     = 67
+	jitterAccesses   = 128
+
+	// jitterStartupSamples is the number of samples taken by the
+	// startup tests.
+	jitterStartupSamples = 1024
+
+	// jitterMaxStuck is the largest fraction of samples that may
+	// be stuck during the startup tests.
+	jitterMaxStuck = 0.9
+)
+
+var ErrJitterStartup = errors.New("fortuna: jitter source failed startup tests")
+
+// JitterCollector gathers entropy from variations in the time taken
+// by memory access and hash loops, as measured with the monotonic
+// clock. The variations come from cache, pipeline, and scheduler
+// state, and need no special hardware. Samples are conditioned with
+// SHA-256 into the collector's output. A JitterCollector is not safe
+// for concurrent use.
+type JitterCollector struct {
+	osr   int
+	rate  float64
+	delta func() int64
+	test  *healthTest
+
+	mem   []byte
+	idx   int
+	sum   [sha256.Size]byte
+	state [sha256.Size]byte
+
+	last, last2 int64
+}
+
+// newJitterCollector returns a collector that has not run its
+// startup tests.
+func newJitterCollector(oversampling int) *JitterCollector {
+	if oversampling <= 0 {
+		oversampling = JitterOversampling
+	}
+	j := &JitterCollector{
+		osr: oversampling,
+		mem: make([]byte, jitterMemorySize),
+	}
+	j.delta = j.measure
+	return j
+}
+
+// NewJitterCollector returns a new collector taking oversampling
+// samples for each bit of output; if oversampling is zero,
+// JitterOversampling is used. The collector's startup tests must
+// pass before it is returned.
+func NewJitterCollector(oversampling int) (*JitterCollector, error) {
+	j := newJitterCollector(oversampling)
+	if err := j.startup(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// measure times one pass of the memory access and hash loops, in
+// nanoseconds.
+func (j *JitterCollector) measure() int64 {
+	start := time.Now()
+	for i := 0; i < jitterAccesses; i++ {
+		j.idx = (j.idx + jitterStride) % len(j.mem)
+		j.mem[j.idx] += j.mem[(j.idx+1)%len(j.mem)] + byte(i)
+	}
+	j.sum = sha256.Sum256(j.sum[:])
+	return int64(time.Since(start))
+}
+
+// stuck returns true if a sample, or its first or second derivative,
+// shows no variation, in which case it is not counted towards the
+// collector's output.
+func (j *JitterCollector) stuck(d int64) bool {
+	d1 := d - j.last
+	d2 := d1 - j.last2
+	j.last, j.last2 = d, d1
+	return d == 0 || d1 == 0 || d2 == 0
+}
+
+// startup runs the startup tests: the timer must show variation in
+// most samples, and the samples must pass the SP 800-90B health tests
+// at the package's default entropy estimate. The collector's own
+// entropy rate is then estimated from the samples, and used to set
+// the cutoffs of the continuous health tests.
+func (j *JitterCollector) startup() error {
+	test := newHealthTest(HealthTestEntropy)
+	var counts [256]int
+	var stuck int
+	for i := 0; i < jitterStartupSamples; i++ {
+		d := j.delta()
+		if j.stuck(d) {
+			stuck++
+		}
+		if !test.sample(byte(d)) {
+			return ErrJitterStartup
+		}
+		counts[byte(d)]++
+	}
+
+	if float64(stuck) > jitterMaxStuck*jitterStartupSamples {
+		return ErrJitterStartup
+	}
+
+	j.rate = mcvEntropy(counts[:], jitterStartupSamples)
+	if j.rate <= 0 {
+		return ErrJitterStartup
+	}
+	j.test = newHealthTest(j.rate)
+	return nil
+}
+
+// mcvEntropy returns the most common value estimate of min-entropy
+// per sample (SP 800-90B, section 6.3.1), using the upper bound of
+// the 99% confidence interval on the most common value's probability.
+func mcvEntropy(counts []int, n int) float64 {
+	var max int
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+	}
+
+	p := float64(max) / float64(n)
+	pu := math.Min(1, p+2.576*math.Sqrt(p*(1-p)/float64(n-1)))
+	return -math.Log2(pu)
+}
+
+// EntropyRate returns the collector's estimate of the min-entropy of
+// each timing sample, in bits, measured during its startup tests.
+func (j *JitterCollector) EntropyRate() float64 {
+	return j.rate
+}
+
+// block collects 8*oversampling samples that are not stuck for each
+// bit of a SHA-256 block, and conditions them into the next block of
+// output. It fails if the samples fail the continuous health tests,
+// or if too many of them are stuck.
+func (j *JitterCollector) block() ([]byte, error) {
+	var buf [8]byte
+	h := sha256.New()
+	h.Write(j.state[:])
+
+	need := 8 * sha256.Size * j.osr
+	for i, tries := 0, 0; i < need; tries++ {
+		if tries > 10*need {
+			return nil, ErrHealthTest
+		}
+
+		d := j.delta()
+		binary.LittleEndian.PutUint64(buf[:], uint64(d))
+		h.Write(buf[:])
+		if !j.test.sample(byte(d)) {
+			return nil, ErrHealthTest
+		}
+		if !j.stuck(d) {
+			i++
+		}
+	}
+
+	h.Sum(j.state[:0])
+	out := sha256.Sum256(append(j.state[:], 1))
+	return out[:], nil
+}
+
+// Read fills p with conditioned output from the collector.
+func (j *JitterCollector) Read(p []byte) (int, error) {
+	var n int
+	for n < len(p) {
+		block, err := j.block()
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], block)
+	}
+	return n, nil
+}
+
+// JitterSourceConfig controls the CPU jitter source.
+type JitterSourceConfig struct {
+	// Oversampling is the number of timing samples collected for
+	// each bit of output. If zero, JitterOversampling is used.
+	Oversampling int
+
+	// Size is the number of bytes delivered on each poll. If
+	// zero, MaxEventSize is used.
+	Size int
+
+	// Interval is the time between polls. If zero,
+	// JitterInterval is used.
+	Interval time.Duration
+
+	// Source configures the registered source.
+	Source *SourceOptions
+}
+
+type jitterPoller struct {
+	j    *JitterCollector
+	size int
+}
+
+func (p *jitterPoller) Poll(ctx context.Context) ([]byte, error) {
+	buf := make([]byte, p.size)
+	if _, err := p.j.Read(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// AddJitter adds a CPU jitter collector, registered as
+// JitterSourceName, once it has passed its startup tests. Its output
+// is credited with the collector's estimated entropy rate, up to one
+// bit per sample. If cfg is nil, the defaults are used.
+func (r *PollRunner) AddJitter(cfg *JitterSourceConfig) error {
+	var jc JitterSourceConfig
+	if cfg != nil {
+		jc = *cfg
+	}
+	if jc.Size <= 0 {
+		jc.Size = MaxEventSize
+	}
+	if jc.Interval <= 0 {
+		jc.Interval = JitterInterval
+	}
+
+	j, err := NewJitterCollector(jc.Oversampling)
+	if err != nil {
+		return err
+	}
+
+	return r.Add(JitterSourceName, &jitterPoller{j: j, size: jc.Size}, &PollerConfig{
+		Interval: jc.Interval,
+		Entropy:  8 * math.Min(j.rate, 1),
+		Source:   jc.Source,
+	})
+}
@@ -0,0 +1,75 @@
+package fortuna
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestJitterCollector(t *testing.T) {
+	j, err := NewJitterCollector(1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if r := j.EntropyRate(); r <= 0 || r > 8 {
+		fmt.Fprintf(os.Stderr, "fortuna: implausible jitter entropy rate %v\n", r)
+		t.FailNow()
+	}
+
+	var p, q = make([]byte, 48), make([]byte, 48)
+	if _, err = j.Read(p); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if _, err = j.Read(q); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if bytes.Equal(p, q) {
+		fmt.Fprintf(os.Stderr, "fortuna: jitter collector repeated its output\n")
+		t.FailNow()
+	}
+}
+
+func TestJitterStartup(t *testing.T) {
+	// A clock that never varies fails the startup tests.
+	j := newJitterCollector(1)
+	j.delta = func() int64 { return 100 }
+	if err := j.startup(); err != ErrJitterStartup {
+		fmt.Fprintf(os.Stderr, "fortuna: constant timer should fail startup (%v)\n", err)
+		t.FailNow()
+	}
+}
+
+func TestJitterHealth(t *testing.T) {
+	j, err := NewJitterCollector(1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	// A timer that gets stuck after startup fails the continuous
+	// tests.
+	j.delta = func() int64 { return 100 }
+	if _, err = j.Read(make([]byte, 32)); err != ErrHealthTest {
+		fmt.Fprintf(os.Stderr, "fortuna: stuck timer should fail health tests (%v)\n", err)
+		t.FailNow()
+	}
+}
+
+func TestJitterSource(t *testing.T) {
+	rng := New()
+	r := rng.StartPollers(context.Background(), nil)
+	defer r.Stop()
+	err := r.AddJitter(&JitterSourceConfig{Oversampling: 1, Interval: time.Hour})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	if ss := waitForEvents(t, rng, 1); ss.Name != JitterSourceName || ss.Entropy == 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad jitter source stats %+v\n", ss)
+		t.FailNow()
+	}
+}
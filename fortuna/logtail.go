@@ -0,0 +1,214 @@
+package fortuna
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+)
+
+// LogInterval is the default interval between polls of a followed
+// log file.
+const LogInterval = time.Second
+
+// LogReadLimit is the most data read from a log file on each poll;
+// the rest is read on later polls.
+const LogReadLimit = 1 << 20
+
+// LogRate and LogBurst are the default rate limit of a log source,
+// in event bytes per second and bytes. Events in excess of the limit
+// are coalesced.
+const (
+	LogRate  = 64
+	LogBurst = 4 * MaxEventSize
+)
+
+// LogSourceConfig controls the log source.
+type LogSourceConfig struct {
+	// Paths lists the log files to follow. Each file is
+	// registered as its own source, named "log:" followed by its
+	// path.
+	Paths []string
+
+	// Interval is the time between polls. If zero, LogInterval is
+	// used.
+	Interval time.Duration
+
+	// FromStart, if true, reads each file from the beginning when
+	// it is first opened, rather than only following new lines.
+	FromStart bool
+
+	// Source configures the registered sources. If nil, each is
+	// limited to LogRate bytes per second, with bursts of
+	// LogBurst bytes, and excess events are coalesced.
+	Source *SourceOptions
+}
+
+// logPoller follows a file like tail -F: when the file is replaced,
+// as on rotation, the rest of the old file is read before the new one
+// is opened from the start, and when it is truncated, it is read again
+// from the start.
+type logPoller struct {
+	path    string
+	f       *os.File
+	fi      os.FileInfo
+	offset  int64
+	buf     []byte
+	partial []byte
+	fromEnd bool
+}
+
+func newLogPoller(path string, fromStart bool) *logPoller {
+	return &logPoller{path: path, fromEnd: !fromStart}
+}
+
+// open opens the log file, positioned at its end if only new lines
+// are to be followed.
+func (p *logPoller) open() error {
+	f, err := os.Open(p.path)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	p.offset = 0
+	if p.fromEnd {
+		p.offset = fi.Size()
+	}
+	p.f, p.fi = f, fi
+	p.partial = nil
+	return nil
+}
+
+func (p *logPoller) Poll(ctx context.Context) ([]byte, error) {
+	if p.f == nil {
+		if err := p.open(); err != nil {
+			if p.fi != nil && os.IsNotExist(err) {
+				// The file is between rotations.
+				return nil, nil
+			}
+			return nil, err
+		}
+		p.fromEnd = false
+	}
+
+	var data []byte
+	fi, err := os.Stat(p.path)
+	switch {
+	case err != nil && !os.IsNotExist(err):
+		return nil, err
+	case err != nil || !os.SameFile(fi, p.fi):
+		// The file was rotated: finish the old file, and open the
+		// new one from the start once it has been read.
+		data, err = p.read()
+		if err != nil || len(data) < LogReadLimit {
+			p.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+	default:
+		if fi.Size() < p.offset {
+			// The file was truncated.
+			p.offset = 0
+			p.partial = nil
+		}
+		if data, err = p.read(); err != nil {
+			return nil, err
+		}
+	}
+	return p.lines(data, time.Now()), nil
+}
+
+// read returns up to LogReadLimit bytes written since the last read.
+// The data is only valid until the next read.
+func (p *logPoller) read() ([]byte, error) {
+	if p.buf == nil {
+		p.buf = make([]byte, LogReadLimit)
+	}
+	n, err := p.f.ReadAt(p.buf, p.offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	p.offset += int64(n)
+	return p.buf[:n], nil
+}
+
+// lines hashes the complete lines in data, with the time they were
+// read, into an event. As the file is polled, that time is only as
+// precise as the poll interval. An incomplete last line is kept until
+// the rest of it is read, unless it reaches LogReadLimit bytes.
+func (p *logPoller) lines(data []byte, now time.Time) []byte {
+	if len(p.partial) > 0 {
+		data = append(p.partial, data...)
+		p.partial = nil
+	}
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		if len(data) < LogReadLimit {
+			p.partial = append([]byte(nil), data...)
+			return nil
+		}
+		// Treat an overlong line as complete.
+		end = len(data)
+	} else {
+		p.partial = append([]byte(nil), data[end+1:]...)
+	}
+
+	var buf [8]byte
+	h := sha256.New()
+	binary.LittleEndian.PutUint64(buf[:], uint64(now.UnixNano()))
+	h.Write(buf[:])
+	h.Write(data[:end])
+	return h.Sum(nil)
+}
+
+// Close closes the log file, if it is open.
+func (p *logPoller) Close() error {
+	if p.f == nil {
+		return nil
+	}
+	err := p.f.Close()
+	p.f = nil
+	return err
+}
+
+// AddLogs adds a poller for each log file in cfg, following it across
+// rotation and truncation. Each poll that reads new lines adds one
+// event, hashed from the lines and the time they were read, which is
+// only as precise as the poll interval; a rate limit keeps a log
+// storm from dominating the pools.
+func (r *PollRunner) AddLogs(cfg *LogSourceConfig) error {
+	var lc LogSourceConfig
+	if cfg != nil {
+		lc = *cfg
+	}
+	if lc.Interval <= 0 {
+		lc.Interval = LogInterval
+	}
+	if lc.Source == nil {
+		lc.Source = &SourceOptions{
+			Rate:      LogRate,
+			Burst:     LogBurst,
+			RateLimit: RateLimitCoalesce,
+		}
+	}
+
+	for _, path := range lc.Paths {
+		err := r.Add("log:"+path, newLogPoller(path, lc.FromStart), &PollerConfig{
+			Interval: lc.Interval,
+			Source:   lc.Source,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
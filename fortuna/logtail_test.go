@@ -0,0 +1,121 @@
+package fortuna
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func appendLog(path, s string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return
+	}
+	f.WriteString(s)
+	f.Close()
+}
+
+func pollLog(t *testing.T, p *logPoller, expected bool) {
+	out, err := p.Poll(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if expected && len(out) != MaxEventSize {
+		fmt.Fprintf(os.Stderr, "fortuna: expected an event from the log\n")
+		t.FailNow()
+	} else if !expected && out != nil {
+		fmt.Fprintf(os.Stderr, "fortuna: unexpected event from the log\n")
+		t.FailNow()
+	}
+}
+
+func TestLogPoller(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	log := filepath.Join(dir, "access.log")
+
+	p := newLogPoller(log, false)
+	defer p.Close()
+	if _, err = p.Poll(context.Background()); err == nil {
+		fmt.Fprintf(os.Stderr, "fortuna: following a missing log should fail\n")
+		t.FailNow()
+	}
+
+	// Lines already in the file when it is opened are skipped.
+	appendLog(log, "old line\n")
+	pollLog(t, p, false)
+	appendLog(log, "GET /\nGET /index")
+	pollLog(t, p, true)
+	pollLog(t, p, false)
+
+	// An incomplete line is kept across reads into the same
+	// buffer.
+	appendLog(log, "?page=2")
+	pollLog(t, p, false)
+	if string(p.partial) != "GET /index?page=2" {
+		fmt.Fprintf(os.Stderr, "fortuna: incomplete line was not kept (%q)\n", p.partial)
+		t.FailNow()
+	}
+	appendLog(log, ".html\n")
+	pollLog(t, p, true)
+
+	// Truncation.
+	ioutil.WriteFile(log, []byte("x\n"), 0644)
+	pollLog(t, p, true)
+
+	// Rotation: the rest of the old file is read, then the new
+	// file is followed from the start.
+	appendLog(log, "last line\n")
+	os.Rename(log, log+".1")
+	pollLog(t, p, true)
+	pollLog(t, p, false)
+	appendLog(log, "first line\n")
+	pollLog(t, p, true)
+}
+
+func TestLogSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	log := filepath.Join(dir, "access.log")
+	appendLog(log, "GET /\n")
+
+	rng := New()
+	r := rng.StartPollers(context.Background(), nil)
+	defer r.Stop()
+	err = r.AddLogs(&LogSourceConfig{
+		Paths:     []string{log},
+		Interval:  time.Millisecond,
+		FromStart: true,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	// A log storm is held to the rate limit.
+	for i := 0; i < 20; i++ {
+		appendLog(log, fmt.Sprintf("GET /%d\n", i))
+		<-time.After(5 * time.Millisecond)
+	}
+
+	ss := rng.Stats().Sources[0]
+	if ss.Name != "log:"+log || ss.Events == 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: log source delivered no events %+v\n", ss)
+		t.FailNow()
+	} else if ss.Bytes > LogBurst+MaxEventSize || ss.Coalesced == 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: log source was not rate limited %+v\n", ss)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,214 @@
+package fortuna
+
+import (
+	"sync"
+	"time"
+)
+
+// ObserverQueueSize is the number of events buffered for each
+// observer. When an observer falls this far behind, further events
+// are dropped for it rather than stalling the PRNG.
+const ObserverQueueSize = 64
+
+// EventKind identifies the type of a lifecycle event.
+type EventKind int
+
+const (
+	// EventReseed is sent when the generator is reseeded from the
+	// pools.
+	EventReseed EventKind = iota
+
+	// EventReady is sent the first time the PRNG is able to
+	// provide random data.
+	EventReady
+
+	// EventSeedLoad is sent when a seed is loaded.
+	EventSeedLoad
+
+	// EventSeedSave is sent when a seed file is written, or when
+	// writing it fails.
+	EventSeedSave
+
+	// EventSourceError is sent when an event from a source is
+	// rejected.
+	EventSourceError
+
+	// EventShutdown is sent when the PRNG is closed.
+	EventShutdown
+
+	// EventSourceSilent is sent by a Watchdog when a source stops
+	// delivering events at its expected rate.
+	EventSourceSilent
+
+	// EventSourceRecovered is sent by a Watchdog when a silent
+	// source starts delivering events again.
+	EventSourceRecovered
+
+	// EventSourceQuarantined is sent when a source fails a health
+	// test and its events start being dropped.
+	EventSourceQuarantined
+
+	// EventSourceReleased is sent when a quarantined source has
+	// passed its probation window and returns to service.
+	EventSourceReleased
+)
+
+var eventNames = map[EventKind]string{
+	EventReseed:      "reseed",
+	EventReady:       "ready",
+	EventSeedLoad:    "seed load",
+	EventSeedSave:    "seed save",
+	EventSourceError: "source error",
+	EventShutdown:    "shutdown",
+
+	EventSourceSilent:    "source silent",
+	EventSourceRecovered: "source recovered",
+
+	EventSourceQuarantined: "source quarantined",
+	EventSourceReleased:    "source released",
+}
+
+func (k EventKind) String() string {
+	if name, ok := eventNames[k]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Event describes something that happened to the PRNG.
+type Event struct {
+	Kind EventKind
+	Time time.Time
+
+	// Reseeds is the value of the reseed counter when the event
+	// occurred.
+	Reseeds uint32
+
+	// Pools has bit i set for each pool i drained by a reseed.
+	Pools uint32
+
+	// Source and SourceID identify the source of a rejected event
+	// or a source whose state has changed; Source is empty if the
+	// source is not registered.
+	Source   string
+	SourceID byte
+
+	// Filename is the seed file loaded or saved, if any.
+	Filename string
+
+	// Err contains the error for source errors and for failed
+	// seed loads and saves.
+	Err error
+}
+
+// Observer receives lifecycle events from the PRNG, such as for
+// audit logging or alerting.
+type Observer interface {
+	Observe(Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(Event)
+
+// Observe calls f(ev).
+func (f ObserverFunc) Observe(ev Event) {
+	f(ev)
+}
+
+type observer struct {
+	o     Observer
+	queue chan Event
+}
+
+type observers struct {
+	sync.Mutex
+	list    []*observer
+	dropped uint64
+	closed  bool
+}
+
+// AddObserver registers an observer with the PRNG and returns a
+// function that removes it. Each observer runs in its own goroutine
+// and is sent events through a queue, so observers never run with
+// any of the PRNG's locks held and a slow observer cannot stall the
+// PRNG; if its queue is full, events are dropped and counted in the
+// PRNG's Stats.
+//
+// Events that occur before the observer is added are not delivered.
+// To observe the initial seed load, create the PRNG with New, add
+// observers, and then load the seed file with UpdateSeed.
+//
+// Closing the PRNG removes every observer once the shutdown event
+// has been delivered, after which remove does nothing. Observers
+// added to a closed PRNG are never sent any events.
+func (rng *Fortuna) AddObserver(o Observer) (remove func()) {
+	obs := &observer{
+		o:     o,
+		queue: make(chan Event, ObserverQueueSize),
+	}
+
+	rng.obs.Lock()
+	defer rng.obs.Unlock()
+	if rng.obs.closed {
+		return func() {}
+	}
+	rng.obs.list = append(rng.obs.list, obs)
+	go func() {
+		for ev := range obs.queue {
+			obs.o.Observe(ev)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			rng.obs.Lock()
+			defer rng.obs.Unlock()
+			for i := range rng.obs.list {
+				if rng.obs.list[i] == obs {
+					rng.obs.list = append(rng.obs.list[:i], rng.obs.list[i+1:]...)
+					close(obs.queue)
+					break
+				}
+			}
+		})
+	}
+}
+
+// closeObservers removes every observer, letting each goroutine exit
+// once it has delivered the events already queued for it.
+func (rng *Fortuna) closeObservers() {
+	rng.obs.Lock()
+	defer rng.obs.Unlock()
+	for _, obs := range rng.obs.list {
+		close(obs.queue)
+	}
+	rng.obs.list = nil
+	rng.obs.closed = true
+}
+
+// emit sends an event to every observer without blocking. It may be
+// called with any of the PRNG's other locks held.
+func (rng *Fortuna) emit(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	rng.obs.Lock()
+	defer rng.obs.Unlock()
+	for _, obs := range rng.obs.list {
+		select {
+		case obs.queue <- ev:
+		default:
+			rng.obs.dropped++
+		}
+	}
+}
+
+// droppedEvents returns the number of events dropped because an
+// observer's queue was full.
+func (rng *Fortuna) droppedEvents() uint64 {
+	rng.obs.Lock()
+	defer rng.obs.Unlock()
+	return rng.obs.dropped
+}
@@ -0,0 +1,149 @@
+package fortuna
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func nextEvent(t *testing.T, events chan Event) Event {
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(time.Second):
+		fmt.Fprintf(os.Stderr, "fortuna: timed out waiting for event\n")
+		t.FailNow()
+	}
+	return Event{}
+}
+
+func TestObserver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	seedFile := filepath.Join(dir, "test.seed")
+
+	events := make(chan Event, ObserverQueueSize)
+	rng := New()
+	remove := rng.AddObserver(ObserverFunc(func(ev Event) { events <- ev }))
+	defer remove()
+
+	for i := 0; i < 2; i++ {
+		rng.AddRandomEvent(0, 0, randomEvent(MaxEventSize))
+	}
+	var p = make([]byte, 16)
+	if _, err = rng.Read(p); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	if ev := nextEvent(t, events); ev.Kind != EventReseed || ev.Reseeds != 1 || ev.Pools != 1 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad reseed event %+v\n", ev)
+		t.FailNow()
+	} else if ev = nextEvent(t, events); ev.Kind != EventReady {
+		fmt.Fprintf(os.Stderr, "fortuna: expected ready event, got %s\n", ev.Kind)
+		t.FailNow()
+	}
+
+	src, _ := rng.RegisterSource("test")
+	src.Add(nil)
+	if ev := nextEvent(t, events); ev.Kind != EventSourceError || ev.Source != "test" || ev.Err != ErrInvalidEvent {
+		fmt.Fprintf(os.Stderr, "fortuna: bad source error event %+v\n", ev)
+		t.FailNow()
+	}
+
+	if err = rng.WriteSeed(seedFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if ev := nextEvent(t, events); ev.Kind != EventSeedSave || ev.Filename != seedFile || ev.Err != nil {
+		fmt.Fprintf(os.Stderr, "fortuna: bad seed save event %+v\n", ev)
+		t.FailNow()
+	}
+
+	if err = rng.UpdateSeed(seedFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if ev := nextEvent(t, events); ev.Kind != EventSeedLoad || ev.Filename != seedFile {
+		fmt.Fprintf(os.Stderr, "fortuna: bad seed load event %+v\n", ev)
+		t.FailNow()
+	}
+	nextEvent(t, events)
+
+	rng.Close()
+	if ev := nextEvent(t, events); ev.Kind != EventShutdown {
+		fmt.Fprintf(os.Stderr, "fortuna: expected shutdown event, got %s\n", ev.Kind)
+		t.FailNow()
+	}
+}
+
+func TestSlowObserver(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	rng := seededRNG(t)
+	remove := rng.AddObserver(ObserverFunc(func(Event) { <-block }))
+	defer remove()
+
+	// Each rejected event is reported to the blocked observer;
+	// once its queue fills, the rest must be dropped rather than
+	// stalling the PRNG.
+	for i := 0; i < 2*ObserverQueueSize; i++ {
+		rng.AddRandomEvent(0, 0, nil)
+	}
+
+	done := make(chan error)
+	go func() {
+		var p = make([]byte, 16)
+		_, err := rng.Read(p)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			t.FailNow()
+		}
+	case <-time.After(time.Second):
+		fmt.Fprintf(os.Stderr, "fortuna: slow observer stalled Read\n")
+		t.FailNow()
+	}
+
+	if rng.Stats().DroppedEvents == 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: dropped events were not counted\n")
+		t.FailNow()
+	}
+}
+
+func TestObserverClose(t *testing.T) {
+	events := make(chan Event, ObserverQueueSize)
+	rng := seededRNG(t)
+	remove := rng.AddObserver(ObserverFunc(func(ev Event) { events <- ev }))
+	obs := rng.obs.list[0]
+
+	rng.Close()
+	if ev := nextEvent(t, events); ev.Kind != EventShutdown {
+		fmt.Fprintf(os.Stderr, "fortuna: expected shutdown event, got %s\n", ev.Kind)
+		t.FailNow()
+	}
+
+	// Closing the PRNG closes each observer's queue, so that its
+	// goroutine exits; removing it afterwards does nothing.
+	if _, ok := <-obs.queue; ok || len(rng.obs.list) != 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: observer was not removed on close\n")
+		t.FailNow()
+	}
+	remove()
+
+	rng.AddObserver(ObserverFunc(func(ev Event) { events <- ev }))()
+	if len(rng.obs.list) != 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: observer added to a closed PRNG\n")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,124 @@
+package fortuna
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"time"
+)
+
+// OSSourceName is the name the operating system source is registered
+// under.
+const OSSourceName = "os"
+
+// OSEventSize and OSInterval are the default number of bytes read
+// from the operating system on each poll, and the interval between
+// polls.
+const (
+	OSEventSize = MaxEventSize
+	OSInterval  = time.Minute
+)
+
+// bootstrapRounds limits the number of times Bootstrap adds an event
+// to every pool while trying to make the first pool ready.
+const bootstrapRounds = 8
+
+var ErrBootstrap = errors.New("fortuna: bootstrap could not fill the first pool")
+
+// OSSourceConfig controls the operating system source.
+type OSSourceConfig struct {
+	// Size is the number of bytes read on each poll. If zero,
+	// OSEventSize is used.
+	Size int
+
+	// Interval is the time between polls. If zero, OSInterval is
+	// used.
+	Interval time.Duration
+
+	// Bootstrap, if true, fills every pool from the operating
+	// system before the source starts polling, so that a new PRNG
+	// can provide random data immediately rather than waiting for
+	// slower sources.
+	Bootstrap bool
+
+	// Source configures the registered source, such as its rate
+	// limit. Bootstrapping is subject to the rate limit, so a
+	// Burst smaller than the bootstrap needs will cause it to
+	// fail.
+	Source *SourceOptions
+}
+
+// osPoller reads from the operating system's random number
+// generator; crypto/rand uses getrandom(2) where it is available.
+type osPoller struct {
+	size int
+	r    io.Reader
+}
+
+func (p *osPoller) Poll(ctx context.Context) ([]byte, error) {
+	buf := make([]byte, p.size)
+	if _, err := io.ReadFull(p.r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// AddOS adds a poller that reads from the operating system's random
+// number generator, registered as OSSourceName. Its output is
+// credited with full entropy. If cfg is nil, the defaults are used.
+func (r *PollRunner) AddOS(cfg *OSSourceConfig) error {
+	return r.addOS(cfg, rand.Reader)
+}
+
+func (r *PollRunner) addOS(cfg *OSSourceConfig, rd io.Reader) error {
+	var oc OSSourceConfig
+	if cfg != nil {
+		oc = *cfg
+	}
+	if oc.Size <= 0 {
+		oc.Size = OSEventSize
+	}
+	if oc.Interval <= 0 {
+		oc.Interval = OSInterval
+	}
+
+	p := &osPoller{size: oc.Size, r: rd}
+	pc := &PollerConfig{
+		Interval: oc.Interval,
+		Entropy:  8,
+		Source:   oc.Source,
+	}
+
+	var init func(*Source) error
+	if oc.Bootstrap {
+		init = func(src *Source) error {
+			return src.bootstrap(rd)
+		}
+	}
+	return r.add(OSSourceName, p, pc, init)
+}
+
+// bootstrap adds a full-entropy event from rd to every pool, in
+// rounds, until the first pool is ready to reseed from.
+func (src *Source) bootstrap(rd io.Reader) error {
+	var e = make([]byte, MaxEventSize)
+	for round := 0; round < bootstrapRounds; round++ {
+		for i := 0; i < PoolSize; i++ {
+			if _, err := io.ReadFull(rd, e); err != nil {
+				return err
+			}
+			if err := src.AddEntropy(e, 8*len(e)); err != nil {
+				return err
+			}
+		}
+
+		src.rng.mu.Lock()
+		ready := src.rng.poolReady()
+		src.rng.mu.Unlock()
+		if ready {
+			return nil
+		}
+	}
+	return ErrBootstrap
+}
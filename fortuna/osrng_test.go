@@ -0,0 +1,91 @@
+package fortuna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestOSBootstrap(t *testing.T) {
+	rng := New()
+	r := rng.StartPollers(context.Background(), nil)
+	defer r.Stop()
+
+	err := r.AddOS(&OSSourceConfig{
+		Interval:  time.Hour,
+		Bootstrap: true,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	st := rng.Stats()
+	for i := range st.Pools {
+		if st.Pools[i] == 0 {
+			fmt.Fprintf(os.Stderr, "fortuna: pool %d was not bootstrapped\n", i)
+			t.FailNow()
+		}
+	}
+	if st.Sources[0].Name != OSSourceName {
+		fmt.Fprintf(os.Stderr, "fortuna: bootstrap should use the OS source %+v\n", st.Sources[0])
+		t.FailNow()
+	}
+
+	var p = make([]byte, 16)
+	if _, err = rng.Read(p); err != nil {
+		fmt.Fprintf(os.Stderr, "fortuna: bootstrapped PRNG should be ready (%v)\n", err)
+		t.FailNow()
+	}
+}
+
+func TestOSPoller(t *testing.T) {
+	rng := New()
+	r := rng.StartPollers(context.Background(), nil)
+	if err := r.AddOS(&OSSourceConfig{Size: 100, Interval: time.Millisecond}); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	<-time.After(20 * time.Millisecond)
+	r.Stop()
+
+	ss := rng.Stats().Sources[0]
+	if ss.Bytes == 0 || ss.Bytes%100 != 0 || ss.Entropy != 8*ss.Bytes {
+		fmt.Fprintf(os.Stderr, "fortuna: bad OS source counts %+v\n", ss)
+		t.FailNow()
+	}
+}
+
+func TestOSBootstrapErrors(t *testing.T) {
+	rng := New()
+	r := rng.StartPollers(context.Background(), nil)
+	defer r.Stop()
+
+	if err := r.addOS(&OSSourceConfig{Bootstrap: true}, failingReader{}); err == nil {
+		fmt.Fprintf(os.Stderr, "fortuna: bootstrap should fail when the OS RNG does\n")
+		t.FailNow()
+	}
+
+	err := r.AddOS(&OSSourceConfig{
+		Bootstrap: true,
+		Source:    &SourceOptions{Rate: 1, Burst: 4 * MaxEventSize},
+	})
+	if err != ErrRateLimited {
+		fmt.Fprintf(os.Stderr, "fortuna: bootstrap should respect the rate limit (%v)\n", err)
+		t.FailNow()
+	}
+
+	if _, err = rng.RegisterSource(OSSourceName); err != nil {
+		fmt.Fprintf(os.Stderr, "fortuna: failed bootstrap should release its source (%v)\n", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,265 @@
+package fortuna
+
+import (
+	"context"
+	"errors"
+	"io"
+	mrand "math/rand"
+	"sync"
+	"time"
+)
+
+// PollInterval is the default interval between polls.
+const PollInterval = 10 * time.Second
+
+// PollMaxBackoff is the default limit on the delay between polls of
+// a failing poller.
+const PollMaxBackoff = 5 * time.Minute
+
+var ErrRunnerStopped = errors.New("fortuna: poll runner stopped")
+
+// A Poller collects entropy on demand. Poll returns the data
+// collected, which may be of any size; it is split into events by
+// the PollRunner. A Poller that also implements io.Closer is closed
+// when its runner stops.
+type Poller interface {
+	Poll(ctx context.Context) ([]byte, error)
+}
+
+// collector is implemented by pollers that compress the data they
+// collect, such as into a digest. The runner credits their Entropy to
+// each byte collected by the last poll, rather than each byte of its
+// output, up to eight bits per byte of output.
+type collector interface {
+	collected() int
+}
+
+// PollerFunc adapts an ordinary function to the Poller interface.
+type PollerFunc func(ctx context.Context) ([]byte, error)
+
+// Poll calls f(ctx).
+func (f PollerFunc) Poll(ctx context.Context) ([]byte, error) {
+	return f(ctx)
+}
+
+// PollerConfig controls how a PollRunner schedules a poller.
+type PollerConfig struct {
+	// Interval is the time between polls. If zero, PollInterval
+	// is used.
+	Interval time.Duration
+
+	// Jitter adds a random delay of up to this duration to each
+	// interval, so that pollers do not run in lockstep.
+	Jitter time.Duration
+
+	// MaxBackoff limits the delay between polls while the poller
+	// is failing; the delay doubles with each consecutive error.
+	// If zero, PollMaxBackoff is used.
+	MaxBackoff time.Duration
+
+	// Entropy is the poller's estimate of the min-entropy of its
+	// output, in bits per byte, credited to each event.
+	Entropy float64
+
+	// Source configures the source the poller is registered as.
+	Source *SourceOptions
+}
+
+// PollRunnerConfig controls a PollRunner.
+type PollRunnerConfig struct {
+	// OnError, if not nil, is called with the name of the poller
+	// and the error whenever a poll fails or its output cannot be
+	// added to the PRNG. It may be called from several goroutines
+	// at once.
+	OnError func(name string, err error)
+}
+
+// PollRunner runs a set of pollers in the background, each at its
+// own interval, and adds their output to the PRNG through a
+// registered source for each poller. A PollRunner is started with
+// StartPollers.
+type PollRunner struct {
+	rng    *Fortuna
+	cfg    PollRunnerConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	done   chan struct{}
+
+	lock    sync.Mutex
+	stopped bool
+}
+
+// StartPollers returns a PollRunner for the PRNG. Pollers are added
+// with its Add method; they stop when ctx is cancelled or the
+// runner's Stop method is called.
+func (rng *Fortuna) StartPollers(ctx context.Context, cfg *PollRunnerConfig) *PollRunner {
+	r := &PollRunner{
+		rng:  rng,
+		done: make(chan struct{}),
+	}
+	if cfg != nil {
+		r.cfg = *cfg
+	}
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	go r.wait()
+	return r
+}
+
+// wait closes the done channel once the runner's context has been
+// cancelled and every poller has exited.
+func (r *PollRunner) wait() {
+	<-r.ctx.Done()
+	r.lock.Lock()
+	r.stopped = true
+	r.lock.Unlock()
+
+	r.wg.Wait()
+	close(r.done)
+}
+
+// Add registers a source under name and starts polling p, which is
+// polled once immediately and then at the configured interval. If
+// cfg is nil, the defaults are used.
+func (r *PollRunner) Add(name string, p Poller, cfg *PollerConfig) error {
+	return r.add(name, p, cfg, nil)
+}
+
+// add registers a source and starts polling p. If init is not nil,
+// it is called with the new source before polling starts; if it
+// fails, the source and poller are closed without being started.
+func (r *PollRunner) add(name string, p Poller, cfg *PollerConfig, init func(*Source) error) error {
+	var pc PollerConfig
+	if cfg != nil {
+		pc = *cfg
+	}
+	if pc.Interval <= 0 {
+		pc.Interval = PollInterval
+	}
+	if pc.MaxBackoff <= 0 {
+		pc.MaxBackoff = PollMaxBackoff
+	}
+	if pc.Entropy < 0 || pc.Entropy > 8 {
+		return ErrInvalidEvent
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.stopped {
+		return ErrRunnerStopped
+	}
+
+	src, err := r.rng.RegisterSourceWithOptions(name, pc.Source)
+	if err != nil {
+		return err
+	}
+
+	if init != nil {
+		if err = init(src); err != nil {
+			src.Close()
+			if c, ok := p.(io.Closer); ok {
+				c.Close()
+			}
+			return err
+		}
+	}
+
+	r.wg.Add(1)
+	go r.run(src, p, &pc)
+	return nil
+}
+
+func (r *PollRunner) run(src *Source, p Poller, pc *PollerConfig) {
+	defer r.wg.Done()
+	defer src.Close()
+	if c, ok := p.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	var failures uint
+	for {
+		delay := pc.Interval
+		data, err := p.Poll(r.ctx)
+		if r.ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			r.report(src.name, err)
+			failures++
+			delay = backoff(pc.Interval, pc.MaxBackoff, failures)
+		} else {
+			failures = 0
+			if _, err = src.addAll(data, credit(p, data, pc.Entropy)); err != nil {
+				r.report(src.name, err)
+			}
+		}
+
+		if pc.Jitter > 0 {
+			delay += time.Duration(mrand.Int63n(int64(pc.Jitter)))
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// credit returns the entropy, in bits per byte, to credit to the data
+// returned by a poll.
+func credit(p Poller, data []byte, bitsPerByte float64) float64 {
+	c, ok := p.(collector)
+	if !ok || len(data) == 0 {
+		return bitsPerByte
+	}
+	bits := bitsPerByte * float64(c.collected()) / float64(len(data))
+	if bits > 8 {
+		bits = 8
+	}
+	return bits
+}
+
+func (r *PollRunner) report(name string, err error) {
+	if r.cfg.OnError != nil {
+		r.cfg.OnError(name, err)
+	}
+}
+
+// backoff returns the delay after the given number of consecutive
+// failures: the interval, doubled for each failure, up to max.
+func backoff(interval, max time.Duration, failures uint) time.Duration {
+	d := interval
+	for i := uint(0); i < failures && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// Done returns a channel that is closed once the runner has been
+// stopped and all of its pollers have exited.
+func (r *PollRunner) Done() <-chan struct{} {
+	return r.done
+}
+
+// Stop halts every poller, waiting for them to exit and closing their
+// sources. No pollers may be added after Stop.
+func (r *PollRunner) Stop() {
+	r.cancel()
+	<-r.done
+}
+
+// addAll splits p into events and adds them in turn, crediting each
+// with bitsPerByte bits of min-entropy per byte. It returns the
+// number of bytes added before the first error.
+func (src *Source) addAll(p []byte, bitsPerByte float64) (int, error) {
+	return splitEvents(p, func(e []byte) error {
+		return src.AddEntropy(e, int(bitsPerByte*float64(len(e))))
+	})
+}
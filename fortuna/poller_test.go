@@ -0,0 +1,151 @@
+package fortuna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type closingPoller struct {
+	lock   sync.Mutex
+	polls  int
+	closed bool
+}
+
+func (p *closingPoller) Poll(ctx context.Context) ([]byte, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.polls++
+	return randomEvent(3*MaxEventSize + 5), nil
+}
+
+func (p *closingPoller) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.closed = true
+	return nil
+}
+
+func TestPollRunner(t *testing.T) {
+	rng := New()
+	r := rng.StartPollers(context.Background(), nil)
+
+	p := &closingPoller{}
+	err := r.Add("poller", p, &PollerConfig{
+		Interval: 5 * time.Millisecond,
+		Jitter:   time.Millisecond,
+		Entropy:  1,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if err = r.Add("poller", p, nil); err != ErrDuplicateSource {
+		fmt.Fprintf(os.Stderr, "fortuna: poller names should be unique (%v)\n", err)
+		t.FailNow()
+	}
+
+	<-time.After(50 * time.Millisecond)
+	ss := rng.Stats().Sources[0]
+	r.Stop()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.polls < 2 {
+		fmt.Fprintf(os.Stderr, "fortuna: poller ran %d times\n", p.polls)
+		t.FailNow()
+	} else if !p.closed {
+		fmt.Fprintf(os.Stderr, "fortuna: poller was not closed on stop\n")
+		t.FailNow()
+	}
+
+	if ss.Name != "poller" || ss.Events < 4 || ss.Events%4 != 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: poll output was not split into events %+v\n", ss)
+		t.FailNow()
+	} else if ss.Entropy != ss.Bytes {
+		fmt.Fprintf(os.Stderr, "fortuna: poller entropy was not credited %+v\n", ss)
+		t.FailNow()
+	}
+
+	if _, err = rng.RegisterSource("poller"); err != nil {
+		fmt.Fprintf(os.Stderr, "fortuna: stopped poller should release its source (%v)\n", err)
+		t.FailNow()
+	} else if err = r.Add("late", p, nil); err != ErrRunnerStopped {
+		fmt.Fprintf(os.Stderr, "fortuna: pollers should not be added after stop (%v)\n", err)
+		t.FailNow()
+	}
+}
+
+func TestPollRunnerErrors(t *testing.T) {
+	errPoll := errors.New("poll failed")
+	var lock sync.Mutex
+	var reported int
+
+	rng := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	r := rng.StartPollers(ctx, &PollRunnerConfig{
+		OnError: func(name string, err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			if name == "failing" && err == errPoll {
+				reported++
+			}
+		},
+	})
+
+	// With a backoff limit of four intervals, the poller can
+	// fail at most a handful of times in the test window.
+	err := r.Add("failing", PollerFunc(func(context.Context) ([]byte, error) {
+		return nil, errPoll
+	}), &PollerConfig{
+		Interval:   5 * time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	<-time.After(100 * time.Millisecond)
+	cancel()
+	select {
+	case <-r.Done():
+	case <-time.After(time.Second):
+		fmt.Fprintf(os.Stderr, "fortuna: runner did not stop on cancel\n")
+		t.FailNow()
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if reported == 0 || reported > 10 {
+		fmt.Fprintf(os.Stderr, "fortuna: poller failed %d times; backoff not applied\n", reported)
+		t.FailNow()
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	var expected = []time.Duration{1, 2, 4, 8, 10, 10}
+	for i, d := range expected {
+		if b := backoff(1, 10, uint(i)); b != d {
+			fmt.Fprintf(os.Stderr, "fortuna: backoff after %d failures is %d, expected %d\n", i, b, d)
+			t.FailNow()
+		}
+	}
+}
+
+// waitForEvents waits for the PRNG's only registered source to
+// deliver at least n events, and returns its stats.
+func waitForEvents(t *testing.T, rng *Fortuna, n uint64) SourceStats {
+	for i := 0; i < 100; i++ {
+		if st := rng.Stats(); len(st.Sources) == 1 && st.Sources[0].Events >= n {
+			return st.Sources[0]
+		}
+		<-time.After(10 * time.Millisecond)
+	}
+	fmt.Fprintf(os.Stderr, "fortuna: source did not deliver %d events\n", n)
+	t.FailNow()
+	return SourceStats{}
+}
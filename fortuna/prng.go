@@ -32,11 +32,13 @@ var (
 	ErrInvalidEvent   = errors.New("fortuna: invalid random event")
 	ErrInvalidSeed    = errors.New("fortuna: invalid seed")
 	ErrNotInitialised = errors.New("fortuna: PRNG not initialised")
+	ErrStale          = errors.New("fortuna: PRNG state may have been duplicated")
 )
 
 type pool struct {
-	hash    []byte
-	written int64
+	hash     []byte
+	written  int64
+	credited int64 // claimed min-entropy, in bits
 	sync.Mutex
 }
 
@@ -45,12 +47,38 @@ type reseedTime struct {
 	sync.Mutex
 }
 
+// Fortuna contains the state of a Fortuna PRNG. The mutex guards
+// the generator, the reseed counter, and the initialisation state;
+// each pool has its own lock.
 type Fortuna struct {
-	initialised bool
-	pools       *[32]*pool
-	counter     uint32
-	g           *Generator
-	lastReseed  *reseedTime
+	mu           sync.Mutex
+	initialised  bool
+	stale        bool
+	epoch        uint32
+	pools        *[32]*pool
+	counter      uint32
+	g            *Generator
+	lastReseed   *reseedTime
+	reseedSubs   map[chan struct{}]bool
+	bytesRead    uint64
+	created      time.Time
+	selfTest     error
+	saveErr      error
+	health       HealthConfig
+	policy       ReadinessPolicy
+	seedLoaded   bool
+	gated        bool
+	readyEmitted bool
+
+	srcLock  sync.Mutex
+	sources  map[string]*Source
+	ids      [256]*Source
+	claimed  [256]bool
+	counters [256]sourceCounters
+	screens  [256]*sourceHealth
+	rejected map[error]uint64
+
+	obs observers
 }
 
 // Initialised returns true if the rng is initialised.
@@ -58,9 +86,95 @@ func (rng *Fortuna) Initialised() bool {
 	if rng == nil {
 		return false
 	}
+	rng.mu.Lock()
+	defer rng.mu.Unlock()
 	return rng.initialised
 }
 
+// Close shuts down the PRNG; once closed, it will no longer provide
+// random data or accept events. Observers are sent a shutdown event
+// and then removed.
+func (rng *Fortuna) Close() error {
+	if !rng.Initialised() {
+		return ErrNotInitialised
+	}
+	rng.mu.Lock()
+	rng.initialised = false
+	rng.emit(Event{Kind: EventShutdown, Reseeds: rng.counter})
+	rng.mu.Unlock()
+	rng.closeObservers()
+	return nil
+}
+
+// checkReady should be called, with the lock held, whenever the PRNG
+// may have become ready; it reports the first time the PRNG is both
+// seeded and past its readiness gate.
+func (rng *Fortuna) checkReady() {
+	if !rng.readyEmitted && rng.counter > 0 && rng.gateOpen() {
+		rng.readyEmitted = true
+		rng.emit(Event{Kind: EventReady, Reseeds: rng.counter})
+	}
+}
+
+// reseeds returns the number of times the PRNG has been reseeded.
+func (rng *Fortuna) reseeds() uint32 {
+	rng.mu.Lock()
+	defer rng.mu.Unlock()
+	return rng.counter
+}
+
+// invalidate marks the PRNG's state as possibly duplicated, such as
+// after a VM snapshot is restored. The PRNG refuses to provide random
+// data until freshReseed is called.
+func (rng *Fortuna) invalidate() {
+	rng.mu.Lock()
+	rng.stale = true
+	rng.epoch++
+	rng.mu.Unlock()
+}
+
+// invalidations returns the number of times the PRNG's state has
+// been invalidated; generators derived from the PRNG use it to
+// detect that they must be reseeded.
+func (rng *Fortuna) invalidations() uint32 {
+	rng.mu.Lock()
+	defer rng.mu.Unlock()
+	return rng.epoch
+}
+
+// freshReseed reseeds the generator with entropy that was collected
+// after the state was invalidated, allowing the PRNG to provide
+// random data again.
+func (rng *Fortuna) freshReseed(p []byte) {
+	rng.mu.Lock()
+	rng.g.Write(p)
+	rng.counter++
+	rng.stale = false
+	rng.checkReady()
+	rng.mu.Unlock()
+}
+
+// notifyReseed returns a channel that receives a value after the
+// PRNG is reseeded. Notifications are coalesced: a slow receiver
+// sees at most one pending notification.
+func (rng *Fortuna) notifyReseed() chan struct{} {
+	ch := make(chan struct{}, 1)
+	rng.mu.Lock()
+	if rng.reseedSubs == nil {
+		rng.reseedSubs = map[chan struct{}]bool{}
+	}
+	rng.reseedSubs[ch] = true
+	rng.mu.Unlock()
+	return ch
+}
+
+// stopNotify removes a channel registered with notifyReseed.
+func (rng *Fortuna) stopNotify(ch chan struct{}) {
+	rng.mu.Lock()
+	delete(rng.reseedSubs, ch)
+	rng.mu.Unlock()
+}
+
 // New sets up a new Fortuna PRNG; it is required for ensuring that
 // the PRNG is properly initialised.
 func New() *Fortuna {
@@ -68,6 +182,9 @@ func New() *Fortuna {
 		pools:      new([32]*pool),
 		g:          NewGenerator(),
 		lastReseed: &reseedTime{},
+		sources:    map[string]*Source{},
+		created:    time.Now(),
+		selfTest:   SelfTest(),
 	}
 
 	for i := range rng.pools {
@@ -81,9 +198,7 @@ func New() *Fortuna {
 }
 
 func (rng *Fortuna) mustReseed() bool {
-	rng.pools[0].Lock()
-	poolReseed := rng.pools[0].written >= MinPoolSize
-	rng.pools[0].Unlock()
+	poolReseed := rng.poolReady()
 
 	rng.lastReseed.Lock()
 	reseed := rng.lastReseed.Time.Add(ReseedDelay)
@@ -91,17 +206,35 @@ func (rng *Fortuna) mustReseed() bool {
 	return poolReseed && time.Now().After(reseed)
 }
 
+// poolReady returns true if the first pool holds enough data, and
+// has been credited with enough entropy, to reseed from. The lock
+// must be held.
+func (rng *Fortuna) poolReady() bool {
+	rng.pools[0].Lock()
+	defer rng.pools[0].Unlock()
+	if rng.policy.MinEntropy > 0 && rng.pools[0].credited < rng.policy.MinEntropy {
+		return false
+	}
+	return rng.pools[0].written >= MinPoolSize
+}
+
 func (rng *Fortuna) reseed() {
 	rng.counter++
 	s := []byte{}
+	var drained uint32
 
+	// Pool i is drained only when 2^i divides the reseed counter,
+	// so the higher pools accumulate entropy for longer.
 	for i := 0; i < len(rng.pools); i++ {
-		if ((1 << uint32(i)) | rng.counter) != 0 {
+		if rng.counter%(1<<uint32(i)) == 0 {
+			drained |= 1 << uint32(i)
 			rng.pools[i].Lock()
 			h := sha256.New()
 			h.Write(rng.pools[i].hash)
 			s = append(s, h.Sum(nil)...)
 			rng.pools[i].hash = []byte{}
+			rng.pools[i].written = 0
+			rng.pools[i].credited = 0
 			rng.pools[i].Unlock()
 		}
 	}
@@ -109,9 +242,31 @@ func (rng *Fortuna) reseed() {
 	rng.lastReseed.Lock()
 	rng.lastReseed.Time = time.Now()
 	rng.lastReseed.Unlock()
+
+	for ch := range rng.reseedSubs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+
+	rng.emit(Event{Kind: EventReseed, Reseeds: rng.counter, Pools: drained})
+	rng.checkReady()
 }
 
+// Read fills p with random data from the PRNG, reseeding first if
+// enough entropy has been collected.
 func (rng *Fortuna) Read(p []byte) (int, error) {
+	rng.mu.Lock()
+	defer rng.mu.Unlock()
+	if !rng.initialised {
+		return 0, ErrNotInitialised
+	} else if rng.stale {
+		return 0, ErrStale
+	} else if !rng.gateOpen() {
+		return 0, ErrNotSeeded
+	}
+
 	if rng.mustReseed() {
 		rng.reseed()
 	}
@@ -119,12 +274,16 @@ func (rng *Fortuna) Read(p []byte) (int, error) {
 	if rng.counter == 0 {
 		return 0, ErrNotSeeded
 	}
+	rng.checkReady()
+	rng.gated = true
 
 	if p == nil {
 		return 0, nil
 	}
 
-	return rng.g.Read(p)
+	n, err := rng.g.Read(p)
+	rng.bytesRead += uint64(n)
+	return n, err
 }
 
 // AddRandomEvent should be called by sources to add random events
@@ -134,16 +293,63 @@ func (rng *Fortuna) Read(p []byte) (int, error) {
 // designers specify that this should be done "in a round-robin
 // fashion." The choice of a source identifier is up to the host
 // application.
+//
+// Most sources should use RegisterSource instead, which assigns a
+// unique identifier and manages the pool rotation; AddRandomEvent
+// remains for sources that need to manage these themselves. The
+// identifiers of registered sources are not available to these
+// sources: using one returns ErrSourceRegistered, and an identifier
+// once used with AddRandomEvent is never assigned by RegisterSource.
 func (rng *Fortuna) AddRandomEvent(s byte, i int, e []byte) error {
 	if !rng.Initialised() {
 		return ErrNotInitialised
 	}
+	if err := rng.claim(s); err != nil {
+		return err
+	}
+	return rng.addEvent(s, i, e, 0)
+}
+
+// AddRandomEventEntropy adds a random event in the same way as
+// AddRandomEvent, along with the source's estimate of the event's
+// min-entropy in bits. The estimate does not change how the event is
+// mixed into the pool; it is tracked for each pool and each source,
+// reported in Stats, and may be used by a ReadinessPolicy. The
+// estimate may not exceed eight bits per byte of the event.
+func (rng *Fortuna) AddRandomEventEntropy(s byte, i int, e []byte, bits int) error {
+	if !rng.Initialised() {
+		return ErrNotInitialised
+	}
+	if err := rng.claim(s); err != nil {
+		return err
+	}
+	return rng.addEvent(s, i, e, bits)
+}
 
-	if e == nil || len(e) == 0 || len(e) > MaxEventSize {
+// validEvent returns true if e is an acceptable event size and bits
+// is a plausible claim of its min-entropy.
+func validEvent(e []byte, bits int) bool {
+	if len(e) == 0 || len(e) > MaxEventSize {
+		return false
+	}
+	return bits >= 0 && bits <= 8*len(e)
+}
+
+// addEvent adds an event to pool i, crediting it with the given
+// number of bits of entropy.
+func (rng *Fortuna) addEvent(s byte, i int, e []byte, bits int) error {
+	if !validEvent(e, bits) {
+		rng.reject(s, ErrInvalidEvent)
 		return ErrInvalidEvent
 	}
 
-	if i < 0 || i > len(rng.pools) {
+	if err := rng.screen(s, e); err != nil {
+		rng.reject(s, err)
+		return err
+	}
+
+	if i < 0 || i >= len(rng.pools) {
+		rng.reject(s, ErrInvalidEvent)
 		return ErrInvalidEvent
 	}
 
@@ -152,6 +358,8 @@ func (rng *Fortuna) AddRandomEvent(s byte, i int, e []byte) error {
 	rng.pools[i].hash = append(rng.pools[i].hash, byte(len(e)))
 	rng.pools[i].hash = append(rng.pools[i].hash, e...)
 	rng.pools[i].written += int64(len(e) + 2)
+	rng.pools[i].credited += int64(bits)
+	rng.accept(s, len(e), bits)
 	rng.pools[i].Unlock()
 	return nil
 }
@@ -179,10 +387,19 @@ func (rng *Fortuna) WriteSeed(filename string) error {
 	}
 
 	seed, err := rng.Seed()
-	if err != nil {
-		return err
+	if err == nil {
+		err = ioutil.WriteFile(filename, seed, 0600)
 	}
-	return ioutil.WriteFile(filename, seed, 0600)
+	rng.saved(filename, err)
+	return err
+}
+
+// saved records the result of writing a seed file.
+func (rng *Fortuna) saved(filename string, err error) {
+	rng.mu.Lock()
+	rng.saveErr = err
+	rng.mu.Unlock()
+	rng.emit(Event{Kind: EventSeedSave, Filename: filename, Err: err})
 }
 
 // UpdateSeed reads a seed from a file and updates the seed file
@@ -193,24 +410,36 @@ func (rng *Fortuna) UpdateSeed(filename string) error {
 	}
 
 	seed, err := ioutil.ReadFile(filename)
+	if err == nil && len(seed) != SeedFileLength {
+		err = ErrInvalidSeed
+	}
 	if err != nil {
+		rng.emit(Event{Kind: EventSeedLoad, Filename: filename, Err: err})
 		return err
-	} else if len(seed) != SeedFileLength {
-		return ErrInvalidSeed
 	}
 
-	rng.g.Write(seed)
+	rng.loadSeed(filename, seed)
 	return rng.WriteSeed(filename)
 }
 
+// loadSeed mixes a seed into the generator.
+func (rng *Fortuna) loadSeed(filename string, p []byte) {
+	rng.mu.Lock()
+	rng.g.Write(p)
+	rng.counter++
+	rng.seedLoaded = true
+	rng.emit(Event{Kind: EventSeedLoad, Reseeds: rng.counter, Filename: filename})
+	rng.checkReady()
+	rng.mu.Unlock()
+}
+
 // ReadSeed reseeds the PRNG with a seed that is expected to have
 // been read from a seed file.
 func (rng *Fortuna) ReadSeed(p []byte) error {
 	if len(p) != SeedFileLength {
 		return ErrInvalidSeed
 	}
-	rng.g.Write(p)
-	rng.counter++
+	rng.loadSeed("", p)
 	return nil
 }
 
@@ -225,38 +454,6 @@ func FromSeed(filename string) (*Fortuna, error) {
 	}
 
 	rng := New()
-	rng.g.Write(seed)
-	rng.counter++
+	rng.loadSeed(filename, seed)
 	return rng, nil
 }
-
-// AutoUpdate runs in the background, updating the PRNG's seed file
-// every ten minutes. The shutdown channel should be closed when the
-// PRNG is to shut down; it will automatically shutdown the PRNG and
-// prevent any state changes. The fsError channel should be used
-// to report errors (typically file system errors). This should
-// never be closed by any other other means.
-func (rng *Fortuna) AutoUpdate(filename string, shutdown chan interface{}, fsError chan error) {
-	go func() {
-		for {
-			select {
-			case _, ok := <-shutdown:
-				if ok {
-					continue
-				}
-				err := rng.WriteSeed(filename)
-				if err != nil {
-					fsError <- err
-				}
-				close(fsError)
-				rng.initialised = false
-				return
-			case <-time.After(10 * time.Minute):
-				err := rng.WriteSeed(filename)
-				if err != nil {
-					fsError <- err
-				}
-			}
-		}
-	}()
-}
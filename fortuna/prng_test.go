@@ -9,6 +9,27 @@ import (
 	"testing"
 )
 
+func seededRNG(t *testing.T) *Fortuna {
+	rng := New()
+	var p = make([]byte, SeedFileLength)
+	if _, err := io.ReadFull(rand.Reader, p); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if err = rng.ReadSeed(p); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	return rng
+}
+
+func randomEvent(n int) []byte {
+	var e = make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, e); err != nil {
+		panic(err)
+	}
+	return e
+}
+
 func TestNilRNG(t *testing.T) {
 	var rng *Fortuna
 	if rng.Initialised() {
@@ -83,13 +104,10 @@ func TestSeed(t *testing.T) {
 		t.FailNow()
 	}
 
-	f, err := os.Open("/dev/zero")
-	if err != nil {
+	if _, err = io.CopyN(sw, rand.Reader, 4096); err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		t.FailNow()
 	}
-
-	io.CopyN(sw, f, 4096)
 	seed, err = rng.Seed()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
@@ -126,13 +144,11 @@ func TestSeedFiles(t *testing.T) {
 	rng := New()
 	sw := NewSourceWriter(rng, 0)
 
-	f, err := os.Open("/dev/zero")
+	_, err := io.CopyN(sw, rand.Reader, 4096)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		t.FailNow()
 	}
-
-	io.CopyN(sw, f, 4096)
 	outFile := "test.seed"
 	defer os.Remove(outFile)
 	if err = rng.WriteSeed(outFile); err != nil {
@@ -192,3 +208,30 @@ func BenchmarkFortunaRead4096(b *testing.B) {
 
 	}
 }
+
+func TestReseedSchedule(t *testing.T) {
+	rng := New()
+	for i := range rng.pools {
+		if err := rng.AddRandomEvent(0, i, []byte{1, 2, 3, 4}); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			t.FailNow()
+		}
+	}
+
+	// The first reseed drains only pool 0; the second drains
+	// pools 0 and 1.
+	rng.reseed()
+	if rng.pools[0].written != 0 || rng.pools[1].written == 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: first reseed should only drain pool 0\n")
+		t.FailNow()
+	}
+
+	rng.reseed()
+	if rng.pools[1].written != 0 || rng.pools[2].written == 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: second reseed should drain pools 0 and 1\n")
+		t.FailNow()
+	} else if rng.pools[PoolSize-1].written == 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: last pool should not have been drained\n")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,108 @@
+package fortuna
+
+import (
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"time"
+)
+
+var ErrRateLimited = errors.New("fortuna: source rate limit exceeded")
+
+// RateLimitPolicy determines what happens to the events a source
+// delivers in excess of its rate limit.
+type RateLimitPolicy int
+
+const (
+	// RateLimitDrop drops excess events, returning
+	// ErrRateLimited.
+	RateLimitDrop RateLimitPolicy = iota
+
+	// RateLimitCoalesce hashes excess events together; the digest
+	// is added to the pools as a single event once the source's
+	// rate limit allows it.
+	RateLimitCoalesce
+)
+
+// rateLimiter is a token bucket, measured in event bytes, along with
+// the state of any events being coalesced. It is guarded by its
+// source's lock.
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	policy RateLimitPolicy
+
+	pending hash.Hash
+	bits    int
+}
+
+// newRateLimiter returns a rate limiter for opts, or nil if the
+// options do not set a rate limit.
+func newRateLimiter(opts *SourceOptions) *rateLimiter {
+	if opts.Rate <= 0 {
+		return nil
+	}
+
+	burst := float64(opts.Burst)
+	if burst < MaxEventSize {
+		burst = MaxEventSize
+	}
+	return &rateLimiter{
+		rate:   opts.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+		policy: opts.RateLimit,
+	}
+}
+
+// refill adds the tokens accrued since the bucket was last refilled.
+func (rl *rateLimiter) refill(now time.Time) {
+	if elapsed := now.Sub(rl.last); elapsed > 0 {
+		rl.tokens += elapsed.Seconds() * rl.rate
+		if rl.tokens > rl.burst {
+			rl.tokens = rl.burst
+		}
+	}
+	rl.last = now
+}
+
+// take spends n tokens if they are available.
+func (rl *rateLimiter) take(n int) bool {
+	if rl.tokens < float64(n) {
+		return false
+	}
+	rl.tokens -= float64(n)
+	return true
+}
+
+// coalesce hashes an excess event into the pending digest. The
+// claimed entropy of the coalesced events is totalled, up to the
+// size of the digest.
+func (rl *rateLimiter) coalesce(e []byte, bits int) {
+	if rl.pending == nil {
+		rl.pending = sha256.New()
+	}
+	rl.pending.Write([]byte{byte(len(e))})
+	rl.pending.Write(e)
+
+	rl.bits += bits
+	if max := 8 * rl.pending.Size(); rl.bits > max {
+		rl.bits = max
+	}
+}
+
+// flush returns the pending digest and its claimed entropy if the
+// bucket allows it to be added, resetting the pending state.
+func (rl *rateLimiter) flush() ([]byte, int, bool) {
+	if rl.pending == nil || !rl.take(rl.pending.Size()) {
+		return nil, 0, false
+	}
+
+	e, bits := rl.pending.Sum(nil), rl.bits
+	rl.pending = nil
+	rl.bits = 0
+	return e, bits, true
+}
@@ -0,0 +1,107 @@
+package fortuna
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestRateLimitDrop(t *testing.T) {
+	rng := New()
+	src, err := rng.RegisterSourceWithOptions("noisy", &SourceOptions{
+		Rate:  1,
+		Burst: 2 * MaxEventSize,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = src.Add(randomEvent(MaxEventSize)); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			t.FailNow()
+		}
+	}
+	for i := 0; i < 8; i++ {
+		if err = src.Add(randomEvent(MaxEventSize)); err != ErrRateLimited {
+			fmt.Fprintf(os.Stderr, "fortuna: event %d should have been rate limited (%v)\n", i, err)
+			t.FailNow()
+		}
+	}
+
+	st := rng.Stats()
+	ss := st.Sources[0]
+	if ss.Events != 2 || ss.RateLimited != 8 || ss.Rejected != 8 || ss.Coalesced != 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad rate limit counts %+v\n", ss)
+		t.FailNow()
+	} else if st.Rejected[ErrRateLimited.Error()] != 8 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad rejected counts %v\n", st.Rejected)
+		t.FailNow()
+	} else if st.Pools[0]+st.Pools[1] != 2*(MaxEventSize+2) {
+		fmt.Fprintf(os.Stderr, "fortuna: rate limited events reached the pools %v\n", st.Pools)
+		t.FailNow()
+	}
+}
+
+func TestRateLimitCoalesce(t *testing.T) {
+	rng := New()
+	src, err := rng.RegisterSourceWithOptions("noisy", &SourceOptions{
+		Rate:      1,
+		RateLimit: RateLimitCoalesce,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	for i := 0; i < 10; i++ {
+		if err = src.AddEntropy(randomEvent(MaxEventSize), 64); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			t.FailNow()
+		}
+	}
+
+	ss := rng.Stats().Sources[0]
+	if ss.Events != 1 || ss.Coalesced != 9 || ss.RateLimited != 0 || ss.Rejected != 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad coalesce counts %+v\n", ss)
+		t.FailNow()
+	}
+
+	// Once the bucket has refilled, the pending digest is added
+	// ahead of the next event.
+	src.limit.tokens = src.limit.burst
+	if err = src.Add(randomEvent(1)); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	st := rng.Stats()
+	ss = st.Sources[0]
+	if ss.Events != 2 || ss.Coalesced != 10 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad counts after flush %+v\n", ss)
+		t.FailNow()
+	} else if ss.Entropy != 64+8*MaxEventSize {
+		fmt.Fprintf(os.Stderr, "fortuna: coalesced entropy should be capped, have %d\n", ss.Entropy)
+		t.FailNow()
+	} else if st.Pools[1] != MaxEventSize+2 {
+		fmt.Fprintf(os.Stderr, "fortuna: digest was not added to the next pool %v\n", st.Pools)
+		t.FailNow()
+	}
+}
+
+func TestRateLimitUnlimited(t *testing.T) {
+	rng := New()
+	src, err := rng.RegisterSource("quiet")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	for i := 0; i < 4*PoolSize; i++ {
+		if err = src.Add(randomEvent(MaxEventSize)); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package fortuna
+
+// ReadinessPolicy sets additional conditions that must be met before
+// the PRNG provides random data. The zero value imposes none, so
+// that only MinPoolSize and ReseedDelay apply.
+type ReadinessPolicy struct {
+	// MinEntropy is the claimed min-entropy, in bits, that must
+	// have been credited to the first pool before each reseed.
+	// Events added without an estimate are credited with none.
+	MinEntropy int64
+
+	// MinSources is the number of distinct registered sources
+	// that must each have contributed at least MinSourceBytes
+	// bytes of events before the PRNG first becomes ready. Until
+	// then, the PRNG neither reseeds from its pools nor provides
+	// random data, even if it was started from a seed file.
+	MinSources     int
+	MinSourceBytes int64
+
+	// SeedCounts, if true, counts a loaded seed as one of the
+	// MinSources.
+	SeedCounts bool
+}
+
+// SetReadinessPolicy sets the conditions under which the PRNG
+// becomes ready and reseeds from its pools. Once the PRNG has
+// provided random data, changes to MinSources no longer have any
+// effect.
+func (rng *Fortuna) SetReadinessPolicy(policy ReadinessPolicy) {
+	rng.mu.Lock()
+	rng.policy = policy
+	rng.mu.Unlock()
+}
+
+// gateSources returns the number of sources counted towards the
+// MinSources requirement. The PRNG's lock must be held.
+func (rng *Fortuna) gateSources() int {
+	var n int
+	if rng.policy.SeedCounts && rng.seedLoaded {
+		n++
+	}
+
+	min := rng.policy.MinSourceBytes
+	if min < 1 {
+		min = 1
+	}
+
+	rng.srcLock.Lock()
+	for id := range rng.ids {
+		if rng.ids[id] != nil && int64(rng.counters[id].bytes) >= min {
+			n++
+		}
+	}
+	rng.srcLock.Unlock()
+	return n
+}
+
+// gateMet returns true if the MinSources requirement is currently
+// met. The PRNG's lock must be held.
+func (rng *Fortuna) gateMet() bool {
+	return rng.policy.MinSources <= 0 || rng.gateSources() >= rng.policy.MinSources
+}
+
+// gateOpen returns true if the MinSources requirement has been met,
+// or if the PRNG has already provided random data. The PRNG's lock
+// must be held.
+func (rng *Fortuna) gateOpen() bool {
+	return rng.gated || rng.gateMet()
+}
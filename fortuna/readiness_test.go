@@ -0,0 +1,137 @@
+package fortuna
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestClaimedEntropy(t *testing.T) {
+	rng := New()
+	src, err := rng.RegisterSource("hwrng")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	if err = src.AddEntropy([]byte{1, 2}, 17); err != ErrInvalidEvent {
+		fmt.Fprintf(os.Stderr, "fortuna: overstated entropy should be rejected\n")
+		t.FailNow()
+	} else if err = rng.AddRandomEventEntropy(9, 0, []byte{1}, -1); err != ErrInvalidEvent {
+		fmt.Fprintf(os.Stderr, "fortuna: negative entropy should be rejected\n")
+		t.FailNow()
+	}
+
+	if err = src.AddEntropy(randomEvent(MaxEventSize), 128); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if err = src.Add([]byte{1, 2}); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if err = rng.AddRandomEventEntropy(9, 0, []byte{1, 2}, 4); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	st := rng.Stats()
+	if st.PoolEntropy[0] != 132 || st.PoolEntropy[1] != 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad pool entropy %v\n", st.PoolEntropy)
+		t.FailNow()
+	} else if st.Sources[0].Entropy != 128 || st.Sources[1].Entropy != 4 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad source entropy %+v\n", st.Sources)
+		t.FailNow()
+	}
+}
+
+func TestReadinessMinEntropy(t *testing.T) {
+	rng := New()
+	rng.SetReadinessPolicy(ReadinessPolicy{MinEntropy: 128})
+
+	// Plenty of bytes, but no claimed entropy.
+	for i := 0; i < 4; i++ {
+		rng.AddRandomEvent(1, 0, randomEvent(MaxEventSize))
+	}
+
+	var p = make([]byte, 16)
+	if _, err := rng.Read(p); err != ErrNotSeeded {
+		fmt.Fprintf(os.Stderr, "fortuna: PRNG should wait for credited entropy\n")
+		t.FailNow()
+	}
+
+	rng.AddRandomEventEntropy(2, 0, randomEvent(MaxEventSize), 128)
+	if _, err := rng.Read(p); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if rng.Stats().PoolEntropy[0] != 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: reseed should reset the pool's credit\n")
+		t.FailNow()
+	}
+}
+
+func TestReadinessMinSources(t *testing.T) {
+	rng := seededRNG(t)
+	rng.SetReadinessPolicy(ReadinessPolicy{
+		MinSources:     3,
+		MinSourceBytes: 64,
+		SeedCounts:     true,
+	})
+
+	var p = make([]byte, 16)
+	if _, err := rng.Read(p); err != ErrNotSeeded {
+		fmt.Fprintf(os.Stderr, "fortuna: seed alone should not make the PRNG ready\n")
+		t.FailNow()
+	} else if h := rng.Health(); h.Status != HealthFailed {
+		fmt.Fprintf(os.Stderr, "fortuna: gated PRNG should fail health check %+v\n", h)
+		t.FailNow()
+	}
+
+	a, _ := rng.RegisterSource("a")
+	b, _ := rng.RegisterSource("b")
+	for i := 0; i < 2; i++ {
+		a.Add(randomEvent(MaxEventSize))
+	}
+	b.Add(randomEvent(MaxEventSize))
+
+	if st := rng.Stats(); st.Ready || st.ReadySources != 2 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad gating status %v/%d\n", st.Ready, st.ReadySources)
+		t.FailNow()
+	} else if _, err := rng.Read(p); err != ErrNotSeeded {
+		fmt.Fprintf(os.Stderr, "fortuna: source b has not contributed enough yet\n")
+		t.FailNow()
+	}
+
+	b.Add(randomEvent(MaxEventSize))
+	if _, err := rng.Read(p); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if st := rng.Stats(); !st.Ready || st.ReadySources != 3 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad gating status %v/%d\n", st.Ready, st.ReadySources)
+		t.FailNow()
+	}
+
+	// Once open, the gate stays open even if sources go away.
+	a.Close()
+	b.Close()
+	if _, err := rng.Read(p); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+}
+
+func TestReadinessSeedDoesNotCount(t *testing.T) {
+	rng := seededRNG(t)
+	rng.SetReadinessPolicy(ReadinessPolicy{MinSources: 1})
+
+	var p = make([]byte, 16)
+	if _, err := rng.Read(p); err != ErrNotSeeded {
+		fmt.Fprintf(os.Stderr, "fortuna: seed should not count as a source\n")
+		t.FailNow()
+	}
+
+	src, _ := rng.RegisterSource("os")
+	src.Add([]byte{1})
+	if _, err := rng.Read(p); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,214 @@
+package fortuna
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDuplicateSource  = errors.New("fortuna: source already registered")
+	ErrTooManySources   = errors.New("fortuna: no source identifiers available")
+	ErrSourceClosed     = errors.New("fortuna: source closed")
+	ErrSourceRegistered = errors.New("fortuna: source identifier belongs to a registered source")
+)
+
+// SilenceFactor is the number of expected intervals a source may go
+// without delivering an event before it is considered silent.
+var SilenceFactor = 3
+
+// SourceOptions configures a registered source.
+type SourceOptions struct {
+	// ExpectedInterval is the expected time between events from
+	// the source. If non-zero, the source is considered silent
+	// once it has gone SilenceFactor intervals without an event;
+	// silent sources are reported by Stats, Health, and the
+	// Watchdog.
+	ExpectedInterval time.Duration
+
+	// HealthTestEntropy is the source's estimate of the
+	// min-entropy per byte of its events, in bits, used to set
+	// the cutoffs of its health tests. If zero, the package
+	// HealthTestEntropy is used.
+	HealthTestEntropy float64
+
+	// Rate limits the source to an average of Rate event bytes
+	// per second, with bursts of up to Burst bytes; Burst is
+	// raised to MaxEventSize if it is smaller. If Rate is zero,
+	// the source is not limited. RateLimit determines what
+	// happens to events in excess of the limit.
+	Rate      float64
+	Burst     int
+	RateLimit RateLimitPolicy
+}
+
+// Source is a handle for a source registered with a Fortuna PRNG.
+// It owns a unique source identifier and distributes its events over
+// the pools in round-robin order, so that a source cannot send every
+// event to the same pool. A Source is safe for concurrent use.
+type Source struct {
+	rng  *Fortuna
+	name string
+	id   byte
+
+	registered time.Time
+	opts       SourceOptions
+
+	lock   sync.Mutex
+	i      int
+	limit  *rateLimiter
+	closed bool
+}
+
+// RegisterSource registers a new source with the PRNG under the
+// given name, assigning it the lowest source identifier that is
+// neither registered nor has been used with AddRandomEvent. Each
+// name may only be registered once; the name is released when the
+// source is closed.
+func (rng *Fortuna) RegisterSource(name string) (*Source, error) {
+	return rng.RegisterSourceWithOptions(name, nil)
+}
+
+// RegisterSourceWithOptions registers a new source in the same way as
+// RegisterSource, configured with opts. If opts is nil, the defaults
+// are used.
+func (rng *Fortuna) RegisterSourceWithOptions(name string, opts *SourceOptions) (*Source, error) {
+	if !rng.Initialised() {
+		return nil, ErrNotInitialised
+	}
+
+	rng.srcLock.Lock()
+	defer rng.srcLock.Unlock()
+	if _, ok := rng.sources[name]; ok {
+		return nil, ErrDuplicateSource
+	}
+
+	for id := range rng.ids {
+		if rng.ids[id] != nil || rng.claimed[id] || byte(id) == snapshotSource {
+			continue
+		}
+
+		src := &Source{
+			rng:        rng,
+			name:       name,
+			id:         byte(id),
+			registered: time.Now(),
+		}
+		if opts != nil {
+			src.opts = *opts
+			src.limit = newRateLimiter(opts)
+		}
+		rng.ids[id] = src
+		rng.sources[name] = src
+		rng.counters[id] = sourceCounters{}
+		rng.screens[id] = nil
+		return src, nil
+	}
+	return nil, ErrTooManySources
+}
+
+// claim reserves a source identifier for a caller of AddRandomEvent,
+// so that it is never assigned to a registered source. It fails if
+// the identifier already belongs to one.
+func (rng *Fortuna) claim(s byte) error {
+	rng.srcLock.Lock()
+	defer rng.srcLock.Unlock()
+	if rng.ids[s] != nil {
+		return ErrSourceRegistered
+	}
+	rng.claimed[s] = true
+	return nil
+}
+
+// Name returns the name the source was registered under.
+func (src *Source) Name() string {
+	return src.name
+}
+
+// ID returns the source identifier assigned to the source.
+func (src *Source) ID() byte {
+	return src.id
+}
+
+// Add adds a random event to the next pool in the source's rotation.
+// The event is subject to the same limits as in AddRandomEvent.
+func (src *Source) Add(e []byte) error {
+	return src.AddEntropy(e, 0)
+}
+
+// AddEntropy adds a random event along with the source's estimate of
+// its min-entropy in bits, as described for AddRandomEventEntropy.
+func (src *Source) AddEntropy(e []byte, bits int) error {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+	if src.closed {
+		return ErrSourceClosed
+	}
+
+	if !src.rng.Initialised() {
+		return ErrNotInitialised
+	}
+
+	if src.limit == nil {
+		return src.add(e, bits)
+	}
+
+	if !validEvent(e, bits) {
+		src.rng.reject(src.id, ErrInvalidEvent)
+		return ErrInvalidEvent
+	}
+
+	src.limit.refill(time.Now())
+	if pending, n, ok := src.limit.flush(); ok {
+		src.add(pending, n)
+	}
+	if src.limit.take(len(e)) {
+		return src.add(e, bits)
+	}
+	return src.throttle(e, bits)
+}
+
+// add adds an event to the next pool in the rotation. The source's
+// lock must be held.
+func (src *Source) add(e []byte, bits int) error {
+	err := src.rng.addEvent(src.id, src.i, e, bits)
+	if err != nil {
+		return err
+	}
+	src.i = (src.i + 1) % len(src.rng.pools)
+	return nil
+}
+
+// throttle handles an event in excess of the source's rate limit,
+// either dropping it or coalescing it for later. Coalesced events are
+// still health tested. The source's lock must be held.
+func (src *Source) throttle(e []byte, bits int) error {
+	if src.limit.policy != RateLimitCoalesce {
+		src.rng.rateLimited(src.id, false)
+		return ErrRateLimited
+	}
+
+	if err := src.rng.screen(src.id, e); err != nil {
+		src.rng.reject(src.id, err)
+		return err
+	}
+	src.limit.coalesce(e, bits)
+	src.rng.rateLimited(src.id, true)
+	return nil
+}
+
+// Close unregisters the source, releasing its name and identifier.
+func (src *Source) Close() error {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+	if src.closed {
+		return ErrSourceClosed
+	}
+	src.closed = true
+
+	src.rng.srcLock.Lock()
+	delete(src.rng.sources, src.name)
+	src.rng.ids[src.id] = nil
+	src.rng.srcLock.Unlock()
+	return nil
+}
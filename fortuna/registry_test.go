@@ -0,0 +1,136 @@
+package fortuna
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestRegisterSource(t *testing.T) {
+	if _, err := (&Fortuna{}).RegisterSource("os"); err != ErrNotInitialised {
+		fmt.Fprintf(os.Stderr, "fortuna: uninitialised PRNG should not register sources\n")
+		t.FailNow()
+	}
+
+	rng := New()
+	a, err := rng.RegisterSource("os")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	b, err := rng.RegisterSource("keyboard")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if a.ID() == b.ID() {
+		fmt.Fprintf(os.Stderr, "fortuna: sources were assigned the same identifier\n")
+		t.FailNow()
+	}
+
+	if _, err = rng.RegisterSource("os"); err != ErrDuplicateSource {
+		fmt.Fprintf(os.Stderr, "fortuna: duplicate registration should be rejected\n")
+		t.FailNow()
+	}
+
+	if err = a.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if err = a.Add([]byte{1}); err != ErrSourceClosed {
+		fmt.Fprintf(os.Stderr, "fortuna: closed source should not add events\n")
+		t.FailNow()
+	} else if a, err = rng.RegisterSource("os"); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+}
+
+func TestTooManySources(t *testing.T) {
+	rng := New()
+	for i := 0; i < 255; i++ {
+		if _, err := rng.RegisterSource(fmt.Sprintf("source-%d", i)); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			t.FailNow()
+		}
+	}
+
+	if _, err := rng.RegisterSource("one too many"); err != ErrTooManySources {
+		fmt.Fprintf(os.Stderr, "fortuna: registry should be full\n")
+		t.FailNow()
+	}
+}
+
+func TestSourceRoundRobin(t *testing.T) {
+	rng := New()
+	src, err := rng.RegisterSource("test")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	for i := 0; i < PoolSize+1; i++ {
+		if err = src.Add([]byte{byte(i)}); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			t.FailNow()
+		}
+	}
+
+	if err = src.Add(make([]byte, MaxEventSize+1)); err != ErrInvalidEvent {
+		fmt.Fprintf(os.Stderr, "fortuna: oversized event should be rejected\n")
+		t.FailNow()
+	}
+
+	for i := range rng.pools {
+		expected := int64(3)
+		if i == 0 {
+			expected = 6
+		}
+		if rng.pools[i].written != expected {
+			fmt.Fprintf(os.Stderr, "fortuna: pool %d has %d bytes, expected %d\n",
+				i, rng.pools[i].written, expected)
+			t.FailNow()
+		} else if rng.pools[i].hash[0] != src.ID() {
+			fmt.Fprintf(os.Stderr, "fortuna: event was not tagged with the source ID\n")
+			t.FailNow()
+		}
+	}
+}
+
+func TestRegisteredIDsDisjoint(t *testing.T) {
+	rng := New()
+	hw, err := rng.RegisterSource("hw")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	// A caller-chosen identifier may not collide with a registered
+	// source, whose health test state it would otherwise share.
+	var zeros = make([]byte, MaxEventSize)
+	if err = rng.AddRandomEvent(hw.ID(), 0, zeros); err != ErrSourceRegistered {
+		fmt.Fprintf(os.Stderr, "fortuna: registered identifier should be rejected (%v)\n", err)
+		t.FailNow()
+	} else if err = rng.AddRandomEventEntropy(hw.ID(), 0, zeros, 0); err != ErrSourceRegistered {
+		fmt.Fprintf(os.Stderr, "fortuna: registered identifier should be rejected (%v)\n", err)
+		t.FailNow()
+	}
+	if err = hw.Add(randomEvent(MaxEventSize)); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	// Once used with AddRandomEvent, an identifier is never
+	// assigned to a registered source.
+	next := hw.ID() + 1
+	if err = rng.AddRandomEvent(next, 0, []byte{1, 2, 3, 4}); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	src, err := rng.RegisterSource("keyboard")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if src.ID() == next {
+		fmt.Fprintf(os.Stderr, "fortuna: claimed identifier was assigned to a source\n")
+		t.FailNow()
+	}
+}
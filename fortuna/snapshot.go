@@ -0,0 +1,198 @@
+package fortuna
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// PoolSnapshotSuffix is appended to the seed file name to produce
+// the name of the pool snapshot written by WriteState.
+const PoolSnapshotSuffix = ".pools"
+
+// poolSnapshotVersion identifies the format of the pool snapshot;
+// it is authenticated along with the snapshot contents.
+const poolSnapshotVersion byte = 1
+
+// snapshotSource is the source identifier used when merging a pool
+// snapshot back into the pools.
+const snapshotSource byte = 0xff
+
+var ErrInvalidSnapshot = errors.New("fortuna: invalid pool snapshot")
+
+// snapshotKey derives the key sealing a pool snapshot from the seed
+// written alongside it. Each seed is only ever written once, so each
+// key seals exactly one snapshot.
+func snapshotKey(seed []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte("fortuna: pool snapshot"))
+	h.Write([]byte{poolSnapshotVersion})
+	h.Write(seed)
+	return h.Sum(nil)
+}
+
+func snapshotAEAD(seed []byte) (cipher.AEAD, error) {
+	key := snapshotKey(seed)
+	defer zero(key)
+
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(c)
+}
+
+// poolDigests returns a digest of each pool's contents. The pools
+// are not drained, and the raw events are never exposed.
+func (rng *Fortuna) poolDigests() []byte {
+	digests := make([]byte, 0, len(rng.pools)*sha256.Size)
+	for i := range rng.pools {
+		rng.pools[i].Lock()
+		h := sha256.New()
+		h.Write([]byte("fortuna: pool digest"))
+		h.Write(rng.pools[i].hash)
+		rng.pools[i].Unlock()
+		digests = append(digests, h.Sum(nil)...)
+	}
+	return digests
+}
+
+// mergePools adds each digest from a snapshot to its pool as an
+// event.
+func (rng *Fortuna) mergePools(digests []byte) {
+	for i := range rng.pools {
+		d := digests[i*sha256.Size : (i+1)*sha256.Size]
+		rng.pools[i].Lock()
+		rng.pools[i].hash = append(rng.pools[i].hash, snapshotSource)
+		rng.pools[i].hash = append(rng.pools[i].hash, byte(len(d)))
+		rng.pools[i].hash = append(rng.pools[i].hash, d...)
+		rng.pools[i].written += int64(len(d) + 2)
+		rng.pools[i].Unlock()
+	}
+}
+
+func sealSnapshot(rng *Fortuna, seed []byte) ([]byte, error) {
+	aead, err := snapshotAEAD(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce = make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rng, nonce); err != nil {
+		return nil, err
+	}
+
+	digests := rng.poolDigests()
+	defer zero(digests)
+
+	out := append([]byte{poolSnapshotVersion}, nonce...)
+	return aead.Seal(out, nonce, digests, out[:1]), nil
+}
+
+func openSnapshot(seed, sealed []byte) ([]byte, error) {
+	aead, err := snapshotAEAD(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < 1+aead.NonceSize() || sealed[0] != poolSnapshotVersion {
+		return nil, ErrInvalidSnapshot
+	}
+	nonce := sealed[1 : 1+aead.NonceSize()]
+	digests, err := aead.Open(nil, nonce, sealed[1+aead.NonceSize():], sealed[:1])
+	if err != nil || len(digests) != PoolSize*sha256.Size {
+		return nil, ErrInvalidSnapshot
+	}
+	return digests, nil
+}
+
+// writeFileAtomic writes data to a temporary file and renames it
+// over filename, so that a crash never leaves a partial file.
+func writeFileAtomic(filename string, data []byte) error {
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// WriteState writes a seed file along with a sealed snapshot of the
+// entropy pools, stored in a file named by appending
+// PoolSnapshotSuffix to filename. The snapshot contains only digests
+// of the pools, encrypted under a key derived from the seed written
+// alongside it; it can only be opened with that seed.
+func (rng *Fortuna) WriteState(filename string) error {
+	if !rng.Initialised() {
+		return ErrNotInitialised
+	}
+
+	seed, err := rng.Seed()
+	if err != nil {
+		return err
+	}
+	defer zero(seed)
+
+	sealed, err := sealSnapshot(rng, seed)
+	if err != nil {
+		return err
+	}
+
+	// The snapshot is written first: if the seed file write
+	// fails, the new snapshot cannot be opened with the old seed
+	// and is discarded on the next start.
+	err = writeFileAtomic(filename+PoolSnapshotSuffix, sealed)
+	if err == nil {
+		err = writeFileAtomic(filename, seed)
+	}
+	rng.saved(filename, err)
+	return err
+}
+
+// FromState creates a new PRNG from a seed file, merging in the pool
+// snapshot written by WriteState if one is present. The snapshot is
+// removed and the seed file rewritten immediately, so neither can be
+// used to restore the same state twice. A snapshot that does not
+// match the seed file, such as one left behind by an interrupted
+// save, is discarded.
+func FromState(filename string) (*Fortuna, error) {
+	seed, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	} else if len(seed) != SeedFileLength {
+		return nil, ErrInvalidSeed
+	}
+	defer zero(seed)
+
+	rng := New()
+	if err = rng.ReadSeed(seed); err != nil {
+		return nil, err
+	}
+
+	snapFile := filename + PoolSnapshotSuffix
+	sealed, err := ioutil.ReadFile(snapFile)
+	if err == nil {
+		digests, err := openSnapshot(seed, sealed)
+		if err == nil {
+			rng.mergePools(digests)
+			zero(digests)
+		}
+		if err = os.Remove(snapFile); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err = rng.WriteSeed(filename); err != nil {
+		return nil, err
+	}
+	return rng, nil
+}
@@ -0,0 +1,112 @@
+package fortuna
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPoolSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	seedFile := filepath.Join(dir, "test.seed")
+	snapFile := seedFile + PoolSnapshotSuffix
+
+	rng := seededRNG(t)
+	event := []byte("a recognisable raw random event")
+	for i := range rng.pools {
+		if err = rng.AddRandomEvent(1, i, event); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			t.FailNow()
+		}
+	}
+
+	if err = rng.WriteState(seedFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	sealed, err := ioutil.ReadFile(snapFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if bytes.Contains(sealed, event) {
+		fmt.Fprintf(os.Stderr, "fortuna: pool snapshot contains raw events\n")
+		t.FailNow()
+	}
+
+	restored, err := FromState(seedFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	for i := range restored.pools {
+		if restored.pools[i].written == 0 {
+			fmt.Fprintf(os.Stderr, "fortuna: pool %d was not restored\n", i)
+			t.FailNow()
+		}
+	}
+
+	if _, err = os.Stat(snapFile); !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "fortuna: pool snapshot should be removed after use\n")
+		t.FailNow()
+	}
+
+	// Replaying the old snapshot against the rewritten seed file
+	// must not restore the pools.
+	if err = ioutil.WriteFile(snapFile, sealed, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	replayed, err := FromState(seedFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if replayed.pools[PoolSize-1].written != 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: stale pool snapshot was merged\n")
+		t.FailNow()
+	}
+}
+
+func TestOpenSnapshot(t *testing.T) {
+	rng := seededRNG(t)
+	seed, err := rng.Seed()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	sealed, err := sealSnapshot(rng, seed)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if _, err = openSnapshot(seed, sealed); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	sealed[0]++
+	if _, err = openSnapshot(seed, sealed); err != ErrInvalidSnapshot {
+		fmt.Fprintf(os.Stderr, "fortuna: snapshot with wrong version should be rejected\n")
+		t.FailNow()
+	}
+	sealed[0]--
+
+	sealed[len(sealed)-1] ^= 1
+	if _, err = openSnapshot(seed, sealed); err != ErrInvalidSnapshot {
+		fmt.Fprintf(os.Stderr, "fortuna: tampered snapshot should be rejected\n")
+		t.FailNow()
+	}
+
+	if _, err = openSnapshot(seed, sealed[:4]); err != ErrInvalidSnapshot {
+		fmt.Fprintf(os.Stderr, "fortuna: truncated snapshot should be rejected\n")
+		t.FailNow()
+	}
+}
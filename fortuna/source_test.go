@@ -2,69 +2,68 @@ package fortuna
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"testing"
 	"time"
 )
 
-func newSourceChannel(cs *SourceChannel) error {
-	f, err := os.Open("/dev/random")
-	if err != nil {
-		return err
-	}
-	buf := new(bytes.Buffer)
-	io.CopyN(buf, f, 4096)
-	f.Close()
+func newSourceChannel(cs *SourceChannel, wg *sync.WaitGroup) {
+	wg.Add(1)
 	go func() {
-		for {
-			if buf.Len() == 0 {
+		defer wg.Done()
+		for i := 0; i < 32; i++ {
+			if err := cs.Send(randomEvent(24)); err != nil {
+				fmt.Fprintf(os.Stderr, "fortuna: error on send (%v)\n", err)
 				return
 			}
-			e := make([]byte, 24)
-			_, err := buf.Read(e)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "fortuna: error on buffer (%v)\n", err)
-				return
-
-			}
-			if cs.In == nil {
-				return
-			}
-			cs.In <- e
-			<-time.After(30 * time.Millisecond)
+			<-time.After(time.Millisecond)
 		}
 	}()
-	return nil
 }
 
 func TestSourceChannel(t *testing.T) {
 	rng := New()
 	cs := NewSourceChannel(rng, 1)
-	cs.Start(4)
-	err := newSourceChannel(cs)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
+	if err := cs.Send(randomEvent(24)); err != ErrChannelNotStarted {
+		fmt.Fprintf(os.Stderr, "fortuna: send before start should fail (%v)\n", err)
 		t.FailNow()
 	}
-	err = newSourceChannel(cs)
+
+	err := cs.Start(context.Background(), &SourceChannelConfig{Buffer: 4})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		t.FailNow()
+	} else if err = cs.Start(context.Background(), nil); err != ErrChannelStarted {
+		fmt.Fprintf(os.Stderr, "fortuna: source channel started twice\n")
+		t.FailNow()
 	}
-	err = newSourceChannel(cs)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		newSourceChannel(cs, &wg)
+	}
+	wg.Wait()
+	cs.Stop()
+
+	if _, ok := <-cs.Errors(); ok {
+		fmt.Fprintf(os.Stderr, "fortuna: unexpected error from source channel\n")
+		t.FailNow()
+	} else if err = cs.Send(randomEvent(24)); err != ErrSourceClosed {
+		fmt.Fprintf(os.Stderr, "fortuna: send after stop should fail (%v)\n", err)
 		t.FailNow()
 	}
-	err = newSourceChannel(cs)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
+
+	st := rng.Stats()
+	if st.Sources[0].Events != 4*32 {
+		fmt.Fprintf(os.Stderr, "fortuna: %d of %d events were added\n", st.Sources[0].Events, 4*32)
 		t.FailNow()
 	}
 
-	<-time.After(1 * time.Second)
 	var p = make([]byte, 16384)
 	n, err := rng.Read(p)
 	if err != nil {
@@ -74,7 +73,110 @@ func TestSourceChannel(t *testing.T) {
 		fmt.Fprintf(os.Stderr, "fortuna: read %d bytes, expected 16384\n", n)
 		t.FailNow()
 	}
+}
+
+// stalledChannel starts a channel source with a buffer of two events
+// whose consumer is stuck adding its first event, so that the buffer
+// can be filled. The returned function releases the consumer.
+func stalledChannel(t *testing.T, policy OverflowPolicy) (*SourceChannel, func()) {
+	rng := New()
+	cs := NewSourceChannel(rng, 1)
+	cs.Start(context.Background(), &SourceChannelConfig{
+		Buffer:   2,
+		Overflow: policy,
+	})
+
+	rng.srcLock.Lock()
+	cs.Send(randomEvent(24))
+	for len(cs.in) != 0 {
+		<-time.After(time.Millisecond)
+	}
+	for i := 0; i < 2; i++ {
+		if err := cs.Send(randomEvent(24)); err != nil {
+			rng.srcLock.Unlock()
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			t.FailNow()
+		}
+	}
+	return cs, rng.srcLock.Unlock
+}
+
+func TestSourceChannelOverflow(t *testing.T) {
+	cs, release := stalledChannel(t, OverflowDropNewest)
+	if err := cs.Send(randomEvent(24)); err != ErrChannelFull {
+		release()
+		fmt.Fprintf(os.Stderr, "fortuna: full channel should drop newest event (%v)\n", err)
+		t.FailNow()
+	}
+	release()
 	cs.Stop()
+	if cs.Dropped() != 1 || cs.rng.Stats().Sources[0].Events != 3 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad counts after dropping newest event\n")
+		t.FailNow()
+	}
+
+	cs, release = stalledChannel(t, OverflowDropOldest)
+	last := randomEvent(24)
+	if err := cs.Send(last); err != nil {
+		release()
+		fmt.Fprintf(os.Stderr, "fortuna: full channel should drop oldest event (%v)\n", err)
+		t.FailNow()
+	}
+	release()
+	cs.Stop()
+	if cs.Dropped() != 1 || cs.rng.Stats().Sources[0].Events != 3 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad counts after dropping oldest event\n")
+		t.FailNow()
+	}
+
+	cs, release = stalledChannel(t, OverflowBlock)
+	sent := make(chan error)
+	go func() { sent <- cs.Send(randomEvent(24)) }()
+	select {
+	case <-sent:
+		release()
+		fmt.Fprintf(os.Stderr, "fortuna: send to full channel should block\n")
+		t.FailNow()
+	case <-time.After(20 * time.Millisecond):
+	}
+	release()
+	if err := <-sent; err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	cs.Stop()
+	if cs.Dropped() != 0 || cs.rng.Stats().Sources[0].Events != 4 {
+		fmt.Fprintf(os.Stderr, "fortuna: blocked event was not added\n")
+		t.FailNow()
+	}
+}
+
+func TestSourceChannelContext(t *testing.T) {
+	rng := New()
+	cs := NewSourceChannel(rng, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cs.Start(ctx, nil)
+	for i := 0; i < 8; i++ {
+		cs.Send(randomEvent(24))
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		cs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		fmt.Fprintf(os.Stderr, "fortuna: source channel did not stop on cancel\n")
+		t.FailNow()
+	}
+
+	if n := rng.Stats().Sources[0].Events; n != 8 {
+		fmt.Fprintf(os.Stderr, "fortuna: %d pending events were drained, expected 8\n", n)
+		t.FailNow()
+	}
 }
 
 func TestSourceWriter(t *testing.T) {
@@ -99,6 +201,108 @@ func TestSourceWriter(t *testing.T) {
 	}
 }
 
+func TestSourceWriterHashWindow(t *testing.T) {
+	rng := New()
+	sw := NewSourceWriter(rng, 2)
+	sw.SetHashWindow(1024)
+
+	n, err := sw.Write(randomEvent(4000))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if n != 4000 {
+		fmt.Fprintf(os.Stderr, "fortuna: wrote %d bytes, expected 4000\n", n)
+		t.FailNow()
+	}
+
+	ss := rng.Stats().Sources[0]
+	if ss.Events != 4 || ss.Bytes != 4*MaxEventSize {
+		fmt.Fprintf(os.Stderr, "fortuna: write was not compressed %+v\n", ss)
+		t.FailNow()
+	}
+
+	// Small writes, and tails no longer than a digest, are added
+	// raw, so that they don't inflate the pool sizes.
+	if _, err = sw.Write(randomEvent(1)); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if _, err = sw.Write(randomEvent(1024 + 5)); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	ss = rng.Stats().Sources[0]
+	if ss.Events != 7 || ss.Bytes != 5*MaxEventSize+1+5 {
+		fmt.Fprintf(os.Stderr, "fortuna: short windows were hashed %+v\n", ss)
+		t.FailNow()
+	}
+}
+
+// sizeReader records the size of the largest read made from it.
+type sizeReader struct {
+	r   io.Reader
+	max int
+}
+
+func (sr *sizeReader) Read(p []byte) (int, error) {
+	if len(p) > sr.max {
+		sr.max = len(p)
+	}
+	return sr.r.Read(p)
+}
+
+func TestSourceWriterReadFrom(t *testing.T) {
+	rng := New()
+	sw := NewSourceWriter(rng, 2)
+	sw.SetHashWindow(1000)
+
+	n, err := sw.ReadFrom(io.LimitReader(rand.Reader, 10000))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if n != 10000 {
+		fmt.Fprintf(os.Stderr, "fortuna: read %d bytes, expected 10000\n", n)
+		t.FailNow()
+	} else if ss := rng.Stats().Sources[0]; ss.Events != 10 {
+		fmt.Fprintf(os.Stderr, "fortuna: reads were not aligned to the window %+v\n", ss)
+		t.FailNow()
+	}
+
+	// A window that divides the read size doesn't grow the buffer.
+	sw.SetHashWindow(1024)
+	sr := &sizeReader{r: io.LimitReader(rand.Reader, 10000)}
+	if _, err = sw.ReadFrom(sr); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if sr.max != sourceReadSize {
+		fmt.Fprintf(os.Stderr, "fortuna: read %d bytes at a time, expected %d\n", sr.max, sourceReadSize)
+		t.FailNow()
+	}
+}
+
+func TestSourceWriterShortWrite(t *testing.T) {
+	rng := New()
+	sw := NewSourceWriter(rng, 2)
+	rng.SetHealthTestEntropy(2, 1)
+
+	// The second event is all zeros, which fails the health
+	// tests.
+	p := append(randomEvent(MaxEventSize), make([]byte, 2*MaxEventSize)...)
+	n, err := sw.Write(p)
+	if err != ErrHealthTest {
+		fmt.Fprintf(os.Stderr, "fortuna: expected health test failure (%v)\n", err)
+		t.FailNow()
+	} else if n != MaxEventSize {
+		fmt.Fprintf(os.Stderr, "fortuna: short write reported %d bytes, expected %d\n", n, MaxEventSize)
+		t.FailNow()
+	}
+
+	m, err := sw.ReadFrom(bytes.NewReader(p))
+	if err != ErrQuarantined || m != 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: ReadFrom should report the error (%d, %v)\n", m, err)
+		t.FailNow()
+	}
+}
+
 func TestUninitialisedPRNG(t *testing.T) {
 	if sc := NewSourceChannel(nil, 3); sc != nil {
 		fmt.Fprintln(os.Stderr, "fortuna: new source should fail for uninitialised PRNG")
@@ -122,3 +326,59 @@ func TestUninitialisedPRNG(t *testing.T) {
 	}
 
 }
+
+func TestRegisteredSourceChannel(t *testing.T) {
+	rng := New()
+	src, err := rng.RegisterSource("channel")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	cs := NewRegisteredSourceChannel(src)
+	cs.Start(context.Background(), nil)
+	for i := 0; i < 8; i++ {
+		cs.Send(randomEvent(24))
+	}
+	cs.Stop()
+
+	ss := rng.Stats().Sources[0]
+	if ss.Name != "channel" || ss.Events != 8 {
+		fmt.Fprintf(os.Stderr, "fortuna: events were not added through the source %+v\n", ss)
+		t.FailNow()
+	}
+	for i := 0; i < 8; i++ {
+		if rng.pools[i].hash[0] != src.ID() {
+			fmt.Fprintf(os.Stderr, "fortuna: event was not added to pool %d\n", i)
+			t.FailNow()
+		}
+	}
+}
+
+func TestRegisteredSourceWriter(t *testing.T) {
+	if sw := NewRegisteredSourceWriter(nil); sw != nil {
+		fmt.Fprintf(os.Stderr, "fortuna: source writer needs a source\n")
+		t.FailNow()
+	}
+
+	// The writer is held to the source's rate limit.
+	rng := New()
+	src, err := rng.RegisterSourceWithOptions("writer", &SourceOptions{
+		Rate:  1,
+		Burst: 2 * MaxEventSize,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	sw := NewRegisteredSourceWriter(src)
+	n, err := sw.Write(randomEvent(4 * MaxEventSize))
+	if err != ErrRateLimited || n != 2*MaxEventSize {
+		fmt.Fprintf(os.Stderr, "fortuna: writer was not rate limited (%d, %v)\n", n, err)
+		t.FailNow()
+	} else if ss := rng.Stats().Sources[0]; ss.Name != "writer" || ss.RateLimited != 1 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad writer source stats %+v\n", ss)
+		t.FailNow()
+	}
+}
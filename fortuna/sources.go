@@ -1,17 +1,85 @@
 package fortuna
 
-import "fmt"
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
 
-// SourceChannel provides an interface to a PRNG that reads random
-// events from a channel and adds them to the PRNG for entropy. The
-// source number s should be used by the application to identify
-// this particular source.
+// SourceChannelBuffer is the default number of events a
+// SourceChannel buffers.
+const SourceChannelBuffer = 16
+
+var (
+	ErrChannelStarted    = errors.New("fortuna: source channel already started")
+	ErrChannelNotStarted = errors.New("fortuna: source channel not started")
+	ErrChannelFull       = errors.New("fortuna: source channel full")
+)
+
+// OverflowPolicy determines what a SourceChannel does with an event
+// sent while its buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the sender until there is room in the
+	// buffer or the channel is stopped.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropNewest drops the event being sent, returning
+	// ErrChannelFull.
+	OverflowDropNewest
+
+	// OverflowDropOldest drops the oldest buffered event to make
+	// room for the one being sent.
+	OverflowDropOldest
+)
+
+// SourceChannelConfig controls a SourceChannel. The zero value
+// buffers SourceChannelBuffer events and blocks senders when the
+// buffer is full.
+type SourceChannelConfig struct {
+	// Buffer is the number of events, and of errors, that may be
+	// buffered. If zero, SourceChannelBuffer is used.
+	Buffer int
+
+	// Overflow determines what happens to events sent while the
+	// buffer is full.
+	Overflow OverflowPolicy
+}
+
+// SourceChannel provides an interface to a PRNG that receives random
+// events from any number of goroutines and adds them to the PRNG for
+// entropy in the background. It adds events either through a
+// registered Source, or with AddRandomEvent under a source number
+// chosen by the application.
+//
+// The channel source owns its channels: events are sent with Send,
+// and errors from the PRNG are received from Errors, which is closed
+// once the source has stopped. A SourceChannel is safe for
+// concurrent use.
 type SourceChannel struct {
 	rng *Fortuna
+	src *Source
 	s   byte
 	i   int
-	In  chan []byte // In receives incoming random events.
-	Out chan error  // Out sends outgoing errors.
+
+	cfg    SourceChannelConfig
+	in     chan []byte
+	out    chan error
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// lock is held for reading by senders, and for writing once
+	// the channel stops, so that no event can be sent after the
+	// buffer has been drained.
+	lock    sync.RWMutex
+	started bool
+	stopped bool
+	dropped uint64
 }
 
 // NewSourceChannel initialises a new channel source. This is
@@ -32,45 +100,210 @@ func NewSourceChannel(rng *Fortuna, source byte) *SourceChannel {
 	}
 }
 
-// Start the channel source, setting up the channel sender and
-// receiver.
-func (cs *SourceChannel) Start(buf int) {
-	cs.In = make(chan []byte, buf)
-	cs.Out = make(chan error, buf)
-	go func() {
+// NewRegisteredSourceChannel initialises a new channel source that
+// adds its events through src, so that they are subject to the
+// source's rate limit and health test options. The channel source
+// must be started before it can be used.
+func NewRegisteredSourceChannel(src *Source) *SourceChannel {
+	if src == nil {
+		return nil
+	}
+	return &SourceChannel{
+		rng: src.rng,
+		src: src,
+	}
+}
+
+// Start the channel source, which runs until ctx is cancelled or
+// Stop is called. If cfg is nil, the defaults are used. A channel
+// source may only be started once.
+func (cs *SourceChannel) Start(ctx context.Context, cfg *SourceChannelConfig) error {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if cs.started {
+		return ErrChannelStarted
+	}
+	cs.started = true
+
+	if cfg != nil {
+		cs.cfg = *cfg
+	}
+	if cs.cfg.Buffer <= 0 {
+		cs.cfg.Buffer = SourceChannelBuffer
+	}
+
+	cs.in = make(chan []byte, cs.cfg.Buffer)
+	cs.out = make(chan error, cs.cfg.Buffer)
+	cs.done = make(chan struct{})
+	cs.ctx, cs.cancel = context.WithCancel(ctx)
+	go cs.run(cs.ctx)
+	return nil
+}
+
+func (cs *SourceChannel) run(ctx context.Context) {
+	defer close(cs.done)
+	defer close(cs.out)
+
+	for {
+		select {
+		case e := <-cs.in:
+			cs.add(e)
+		case <-ctx.Done():
+			// Wait for any senders to finish, then add
+			// whatever is left in the buffer.
+			cs.lock.Lock()
+			cs.stopped = true
+			cs.lock.Unlock()
+
+			for {
+				select {
+				case e := <-cs.in:
+					cs.add(e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// add adds an event to the PRNG, reporting any error without
+// blocking.
+func (cs *SourceChannel) add(e []byte) {
+	var err error
+	if cs.src != nil {
+		err = cs.src.Add(e)
+	} else {
+		err = cs.rng.AddRandomEvent(cs.s, cs.i, e)
+		cs.i = (cs.i + 1) % len(cs.rng.pools)
+	}
+	if err != nil {
+		select {
+		case cs.out <- err:
+		default:
+		}
+	}
+}
+
+// Send queues an event to be added to the PRNG, applying the
+// channel's overflow policy if its buffer is full. It returns
+// ErrSourceClosed once the channel has stopped.
+func (cs *SourceChannel) Send(e []byte) error {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	if !cs.started {
+		return ErrChannelNotStarted
+	} else if cs.stopped {
+		return ErrSourceClosed
+	}
+
+	switch cs.cfg.Overflow {
+	case OverflowDropNewest:
+		select {
+		case cs.in <- e:
+			return nil
+		default:
+			atomic.AddUint64(&cs.dropped, 1)
+			return ErrChannelFull
+		}
+	case OverflowDropOldest:
 		for {
-			e, ok := <-cs.In
-			if !ok {
-				return
+			select {
+			case cs.in <- e:
+				return nil
+			default:
 			}
-			err := cs.rng.AddRandomEvent(cs.s, cs.i, e)
-			if err != nil {
-				cs.Out <- err
+
+			select {
+			case <-cs.in:
+				atomic.AddUint64(&cs.dropped, 1)
+			default:
 			}
-			cs.i = (cs.i + 1) % len(cs.rng.pools)
 		}
-	}()
+	default:
+		select {
+		case cs.in <- e:
+			return nil
+		case <-cs.ctx.Done():
+			return ErrSourceClosed
+		}
+	}
 }
 
-// Stop halts the channel source, closing the channels.
+// Errors returns the channel on which errors from the PRNG are
+// reported. Errors are dropped if the channel is not being received
+// from. It is closed once the source has stopped, and is nil before
+// the source is started.
+func (cs *SourceChannel) Errors() <-chan error {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	return cs.out
+}
+
+// Dropped returns the number of events dropped by the overflow
+// policy.
+func (cs *SourceChannel) Dropped() uint64 {
+	return atomic.LoadUint64(&cs.dropped)
+}
+
+// Wait blocks until the channel source has stopped and every event
+// sent before it stopped has been added to the PRNG.
+func (cs *SourceChannel) Wait() {
+	cs.lock.RLock()
+	done := cs.done
+	cs.lock.RUnlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// Stop halts the channel source, adding any buffered events to the
+// PRNG before returning.
 func (cs *SourceChannel) Stop() {
-	if cs.In != nil {
-		close(cs.In)
-		cs.In = nil
+	cs.lock.RLock()
+	cancel := cs.cancel
+	cs.lock.RUnlock()
+	if cancel != nil {
+		cancel()
 	}
+	cs.Wait()
+}
+
+// sourceReadSize is the size of the reads made by
+// SourceWriter.ReadFrom.
+const sourceReadSize = 4096
+
+// splitEvents splits p into events of at most MaxEventSize bytes and
+// passes them to add in turn. It returns the number of bytes added
+// before the first error.
+func splitEvents(p []byte, add func(e []byte) error) (int, error) {
+	var n int
+	for len(p) > 0 {
+		e := p
+		if len(e) > MaxEventSize {
+			e = e[:MaxEventSize]
+		}
 
-	if cs.Out != nil {
-		close(cs.Out)
-		cs.Out = nil
+		if err := add(e); err != nil {
+			return n, err
+		}
+		n += len(e)
+		p = p[len(e):]
 	}
+	return n, nil
 }
 
 // SourceWriter provides an io.Writer source for adding events to
-// the PRNG.
+// the PRNG. By default, writes are split into events of
+// MaxEventSize bytes; SetHashWindow compresses large writes
+// instead. Like a SourceChannel, it adds events either through a
+// registered Source or with AddRandomEvent.
 type SourceWriter struct {
-	rng *Fortuna
-	s   byte
-	i   int
+	rng    *Fortuna
+	src    *Source
+	s      byte
+	i      int
+	window int
 }
 
 // NewSourceWriter intialises a new io.Writer source. This is
@@ -88,29 +321,108 @@ func NewSourceWriter(rng *Fortuna, source byte) *SourceWriter {
 	}
 }
 
-// Write adds the byte slice as entropy to the pools in the PRNG.
+// NewRegisteredSourceWriter initialises a new io.Writer source that
+// adds its events through src, so that they are subject to the
+// source's rate limit and health test options.
+func NewRegisteredSourceWriter(src *Source) *SourceWriter {
+	if src == nil {
+		return nil
+	}
+	return &SourceWriter{
+		rng: src.rng,
+		src: src,
+	}
+}
+
+// SetHashWindow sets the size of the windows that writes are hashed
+// in. If n is greater than MaxEventSize, each window of n bytes is
+// compressed with SHA-256 into a single event, so that a large write
+// adds one event per window rather than filling every pool with raw
+// data. A window that would be no longer than its digest, such as a
+// small write or the tail of a large one, is added raw. Otherwise,
+// writes are split into raw events.
+func (sw *SourceWriter) SetHashWindow(n int) {
+	if n <= MaxEventSize {
+		n = 0
+	}
+	sw.window = n
+}
+
+// Write adds the byte slice as entropy to the pools in the PRNG. If
+// an event is rejected, Write returns the number of bytes added
+// before it along with the error.
 func (sw *SourceWriter) Write(p []byte) (int, error) {
-	if p == nil {
-		return 0, nil
+	if sw.window == 0 {
+		return splitEvents(p, sw.add)
 	}
-	pp := p
-	k := len(p) / MaxEventSize
-	if len(p)%MaxEventSize != 0 {
-		k++
+
+	var n int
+	for len(p) > 0 {
+		w := p
+		if len(w) > sw.window {
+			w = w[:sw.window]
+		}
+
+		if len(w) <= MaxEventSize {
+			m, err := splitEvents(w, sw.add)
+			if err != nil {
+				return n + m, err
+			}
+		} else {
+			sum := sha256.Sum256(w)
+			if err := sw.add(sum[:]); err != nil {
+				return n, err
+			}
+		}
+		n += len(w)
+		p = p[len(w):]
 	}
+	return n, nil
+}
 
-	for i := 0; i < k; i++ {
-		wrsz := MaxEventSize
-		if i == (k-1) && ((len(pp) % MaxEventSize) != 0) {
-			wrsz = len(pp) % MaxEventSize
+func (sw *SourceWriter) add(e []byte) error {
+	if sw.src != nil {
+		return sw.src.Add(e)
+	}
+
+	err := sw.rng.AddRandomEvent(sw.s, sw.i, e)
+	if err != nil {
+		return err
+	}
+	sw.i = (sw.i + 1) % len(sw.rng.pools)
+	return nil
+}
+
+// ReadFrom adds everything read from r to the PRNG until EOF,
+// returning the number of bytes added. It implements io.ReaderFrom,
+// so that io.Copy streams files and pipes into the PRNG through a
+// buffer of whole hash windows. Reads are aligned to the hash window,
+// so that the events added do not depend on how r splits its data.
+func (sw *SourceWriter) ReadFrom(r io.Reader) (int64, error) {
+	size := sourceReadSize
+	if sw.window > 0 {
+		size = size / sw.window * sw.window
+		if size < sw.window {
+			size = sw.window
 		}
-		err := sw.rng.AddRandomEvent(sw.s, sw.i, pp[:wrsz])
-		sw.i = (sw.i + 1) % len(sw.rng.pools)
-		if err != nil {
-			fmt.Printf("%d, wrsz: %d, len(p): %d\n", i, wrsz, len(p))
-			return len(p) - len(pp), err
+	}
+	buf := make([]byte, size)
+
+	var total int64
+	for {
+		m, err := io.ReadFull(r, buf)
+		if m > 0 {
+			n, werr := sw.Write(buf[:m])
+			total += int64(n)
+			if werr != nil {
+				return total, werr
+			}
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return total, nil
+		} else if err != nil {
+			return total, err
 		}
-		pp = pp[wrsz:]
 	}
-	return len(p), nil
 }
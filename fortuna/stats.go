@@ -0,0 +1,229 @@
+package fortuna
+
+import "time"
+
+// sourceCounters records the activity of a single source
+// identifier. It is guarded by the PRNG's source lock.
+type sourceCounters struct {
+	events    uint64
+	bytes     uint64
+	entropy   uint64
+	rejected  uint64
+	dropped   uint64
+	coalesced uint64
+	lastEvent time.Time
+}
+
+// SourceStats describes the activity of a single source.
+type SourceStats struct {
+	// Name is the name the source was registered under; it is
+	// empty for sources that only use AddRandomEvent.
+	Name string
+
+	ID         byte
+	Events     uint64    // events added to the pools
+	Bytes      uint64    // event bytes added to the pools
+	Entropy    uint64    // claimed min-entropy added, in bits
+	Rejected   uint64    // events that were not added
+	LastEvent  time.Time // time of the last event added
+	Registered time.Time // time the source was registered
+
+	// ExpectedInterval is the expected time between events that
+	// the source was registered with, and Silent is true if the
+	// source has gone SilenceFactor intervals without an event.
+	ExpectedInterval time.Duration
+	Silent           bool
+
+	// Quarantined is true if the source's events are being
+	// dropped after failing a health test, and HealthFailures
+	// counts its health test failures.
+	Quarantined    bool
+	HealthFailures uint64
+
+	// RateLimited counts the events dropped for exceeding the
+	// source's rate limit, and Coalesced the events hashed
+	// together instead. Dropped events are also counted as
+	// rejected.
+	RateLimited uint64
+	Coalesced   uint64
+}
+
+// idle returns the time since the source last delivered an event, or
+// since it was registered if it has not delivered any.
+func (ss *SourceStats) idle(now time.Time) time.Duration {
+	last := ss.LastEvent
+	if last.Before(ss.Registered) {
+		last = ss.Registered
+	}
+	return now.Sub(last)
+}
+
+// silent returns true if the source has gone SilenceFactor expected
+// intervals without an event as of now.
+func (ss *SourceStats) silent(now time.Time) bool {
+	if ss.ExpectedInterval <= 0 {
+		return false
+	}
+	return ss.idle(now) > time.Duration(SilenceFactor)*ss.ExpectedInterval
+}
+
+// Stats is a snapshot of the PRNG's state, suitable for monitoring.
+type Stats struct {
+	// Pools contains the number of bytes written to each pool
+	// since it was last drained.
+	Pools [PoolSize]int64
+
+	// PoolEntropy contains the claimed min-entropy, in bits,
+	// credited to each pool since it was last drained.
+	PoolEntropy [PoolSize]int64
+
+	Reseeds    uint32    // number of reseeds so far
+	LastReseed time.Time // time of the last reseed from the pools
+	BytesRead  uint64    // bytes of random data read
+
+	// Ready is true if the PRNG is seeded and its readiness
+	// policy has been met.
+	Ready bool
+
+	// ReadySources is the number of sources currently counted
+	// towards the MinSources requirement of the readiness policy.
+	ReadySources int
+
+	// Sources describes every registered source and every source
+	// identifier that has been used with AddRandomEvent, ordered
+	// by identifier.
+	Sources []SourceStats
+
+	// Rejected counts the events that were not added to the
+	// pools, keyed by the error that caused them to be rejected.
+	Rejected map[string]uint64
+
+	// DroppedEvents counts the lifecycle events that were not
+	// delivered because an observer's queue was full.
+	DroppedEvents uint64
+}
+
+// accept records an event added to the pools by source s. The
+// caller must hold the lock of the pool the event was added to.
+func (rng *Fortuna) accept(s byte, n, bits int) {
+	rng.srcLock.Lock()
+	c := &rng.counters[s]
+	c.events++
+	c.bytes += uint64(n)
+	c.entropy += uint64(bits)
+	c.lastEvent = time.Now()
+	rng.srcLock.Unlock()
+}
+
+// reject records an event from source s that was rejected with err.
+func (rng *Fortuna) reject(s byte, err error) {
+	rng.srcLock.Lock()
+	rng.counters[s].rejected++
+	if rng.rejected == nil {
+		rng.rejected = map[error]uint64{}
+	}
+	rng.rejected[err]++
+	var name string
+	if src := rng.ids[s]; src != nil {
+		name = src.name
+	}
+	rng.srcLock.Unlock()
+
+	rng.emit(Event{
+		Kind:     EventSourceError,
+		Source:   name,
+		SourceID: s,
+		Err:      err,
+	})
+}
+
+// rateLimited records an event from source s in excess of its rate
+// limit. Dropped events are counted as rejected, but unlike other
+// rejections they are not reported to observers, so that a flooding
+// source cannot also flood the observers.
+func (rng *Fortuna) rateLimited(s byte, coalesced bool) {
+	rng.srcLock.Lock()
+	defer rng.srcLock.Unlock()
+	c := &rng.counters[s]
+	if coalesced {
+		c.coalesced++
+		return
+	}
+
+	c.dropped++
+	c.rejected++
+	if rng.rejected == nil {
+		rng.rejected = map[error]uint64{}
+	}
+	rng.rejected[ErrRateLimited]++
+}
+
+// Stats returns a snapshot of the PRNG's state. The snapshot is
+// taken with every lock held, so its counters are consistent with
+// each other.
+func (rng *Fortuna) Stats() *Stats {
+	st := &Stats{
+		Rejected: map[string]uint64{},
+	}
+
+	rng.mu.Lock()
+	defer rng.mu.Unlock()
+	st.Reseeds = rng.counter
+	st.BytesRead = rng.bytesRead
+	st.ReadySources = rng.gateSources()
+	st.Ready = rng.counter > 0 && rng.gateOpen()
+	if rng.lastReseed != nil {
+		rng.lastReseed.Lock()
+		st.LastReseed = rng.lastReseed.Time
+		rng.lastReseed.Unlock()
+	}
+
+	if rng.pools != nil {
+		for i := range rng.pools {
+			rng.pools[i].Lock()
+			defer rng.pools[i].Unlock()
+			st.Pools[i] = rng.pools[i].written
+			st.PoolEntropy[i] = rng.pools[i].credited
+		}
+	}
+
+	now := time.Now()
+	rng.srcLock.Lock()
+	defer rng.srcLock.Unlock()
+	for id := range rng.counters {
+		c := &rng.counters[id]
+		src := rng.ids[id]
+		if src == nil && c.events == 0 && c.rejected == 0 && c.coalesced == 0 {
+			continue
+		}
+
+		ss := SourceStats{
+			ID:        byte(id),
+			Events:    c.events,
+			Bytes:     c.bytes,
+			Entropy:   c.entropy,
+			Rejected:  c.rejected,
+			LastEvent: c.lastEvent,
+
+			RateLimited: c.dropped,
+			Coalesced:   c.coalesced,
+		}
+		if sh := rng.screens[id]; sh != nil {
+			ss.Quarantined = sh.quarantined
+			ss.HealthFailures = sh.failures
+		}
+		if src != nil {
+			ss.Name = src.name
+			ss.Registered = src.registered
+			ss.ExpectedInterval = src.opts.ExpectedInterval
+			ss.Silent = ss.silent(now)
+		}
+		st.Sources = append(st.Sources, ss)
+	}
+
+	for err, n := range rng.rejected {
+		st.Rejected[err.Error()] = n
+	}
+	st.DroppedEvents = rng.droppedEvents()
+	return st
+}
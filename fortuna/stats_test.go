@@ -0,0 +1,78 @@
+package fortuna
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	rng := New()
+	src, err := rng.RegisterSource("test")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	for i := 0; i < 2*PoolSize; i++ {
+		if err = src.Add(randomEvent(MaxEventSize)); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			t.FailNow()
+		}
+	}
+	src.Add(nil)
+	rng.AddRandomEvent(200, 0, []byte{1, 2})
+	rng.AddRandomEvent(200, PoolSize, []byte{1, 2})
+
+	var p = make([]byte, 100)
+	if _, err = rng.Read(p); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	st := rng.Stats()
+	if st.Reseeds != 1 || st.LastReseed.IsZero() {
+		fmt.Fprintf(os.Stderr, "fortuna: stats should record the reseed\n")
+		t.FailNow()
+	} else if st.BytesRead != 100 {
+		fmt.Fprintf(os.Stderr, "fortuna: stats report %d bytes read\n", st.BytesRead)
+		t.FailNow()
+	} else if st.Pools[0] != 0 || st.Pools[1] != 2*(MaxEventSize+2) {
+		fmt.Fprintf(os.Stderr, "fortuna: bad pool counts %v\n", st.Pools)
+		t.FailNow()
+	} else if st.Rejected[ErrInvalidEvent.Error()] != 2 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad rejected counts %v\n", st.Rejected)
+		t.FailNow()
+	}
+
+	if len(st.Sources) != 2 {
+		fmt.Fprintf(os.Stderr, "fortuna: expected two sources, have %d\n", len(st.Sources))
+		t.FailNow()
+	}
+
+	ss := st.Sources[0]
+	if ss.Name != "test" || ss.ID != src.ID() {
+		fmt.Fprintf(os.Stderr, "fortuna: bad source %+v\n", ss)
+		t.FailNow()
+	} else if ss.Events != 2*PoolSize || ss.Bytes != 2*PoolSize*MaxEventSize || ss.Rejected != 1 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad source counts %+v\n", ss)
+		t.FailNow()
+	}
+
+	ss = st.Sources[1]
+	if ss.Name != "" || ss.ID != 200 || ss.Events != 1 || ss.Rejected != 1 {
+		fmt.Fprintf(os.Stderr, "fortuna: bad unregistered source %+v\n", ss)
+		t.FailNow()
+	}
+}
+
+func BenchmarkStats(b *testing.B) {
+	rng := New()
+	for i := 0; i < 8; i++ {
+		rng.RegisterSource(fmt.Sprintf("source-%d", i))
+	}
+
+	for i := 0; i < b.N; i++ {
+		rng.Stats()
+	}
+}
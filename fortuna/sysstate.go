@@ -0,0 +1,183 @@
+package fortuna
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+)
+
+// SystemSourceName is the name the system state source is registered
+// under.
+const SystemSourceName = "system"
+
+// SystemRoot and SystemInterval are the default base path of the
+// system state files and the interval between polls.
+const (
+	SystemRoot     = "/proc"
+	SystemInterval = 5 * time.Second
+)
+
+// DefaultSystemFiles lists the kernel counters read by the system
+// state source, relative to its base path. Files that do not exist on
+// the host, such as the pressure stall files on older kernels, are
+// skipped.
+var DefaultSystemFiles = []string{
+	"interrupts",
+	"stat",
+	"diskstats",
+	"net/dev",
+	"self/sched",
+	"pressure/cpu",
+	"pressure/io",
+	"pressure/memory",
+}
+
+var ErrNoSystemFiles = errors.New("fortuna: no system state files could be read")
+
+// SystemSourceConfig controls the system state source.
+type SystemSourceConfig struct {
+	// Root is the base path of the files. If empty, SystemRoot is
+	// used.
+	Root string
+
+	// Files lists the files to read, relative to Root. If empty,
+	// DefaultSystemFiles is used.
+	Files []string
+
+	// Interval is the time between polls. If zero, SystemInterval
+	// is used.
+	Interval time.Duration
+
+	// Entropy is the min-entropy credited to each byte of output,
+	// in bits. The default of zero credits none.
+	Entropy float64
+
+	// Source configures the registered source.
+	Source *SourceOptions
+}
+
+// systemPoller reads a set of kernel counter files, and hashes the
+// change in each file's counters since the last poll, along with the
+// time it was read, into an event.
+type systemPoller struct {
+	root  string
+	files []string
+	last  map[string][]uint64
+}
+
+func newSystemPoller(root string, files []string) *systemPoller {
+	return &systemPoller{
+		root:  root,
+		files: files,
+		last:  map[string][]uint64{},
+	}
+}
+
+// counters returns every decimal number in a file, in order.
+func counters(p []byte) []uint64 {
+	var values []uint64
+	var v uint64
+	var in bool
+	for _, b := range p {
+		if b >= '0' && b <= '9' {
+			v = v*10 + uint64(b-'0')
+			in = true
+			continue
+		}
+		if in {
+			values = append(values, v)
+			v, in = 0, false
+		}
+	}
+	if in {
+		values = append(values, v)
+	}
+	return values
+}
+
+// delta returns the difference between two snapshots of a file's
+// counters, and whether any counter changed. If the layout of the
+// file changed, as when a device is added, the new values are used
+// as they are.
+func delta(cur, prev []uint64) ([]uint64, bool) {
+	if len(cur) != len(prev) {
+		return cur, len(cur) > 0
+	}
+
+	var changed bool
+	d := make([]uint64, len(cur))
+	for i := range cur {
+		d[i] = cur[i] - prev[i]
+		if d[i] != 0 {
+			changed = true
+		}
+	}
+	return d, changed
+}
+
+func (p *systemPoller) Poll(ctx context.Context) ([]byte, error) {
+	var out []byte
+	var read int
+	var buf [8]byte
+	for _, name := range p.files {
+		data, err := ioutil.ReadFile(filepath.Join(p.root, name))
+		now := time.Now()
+		if err != nil {
+			continue
+		}
+		read++
+
+		cur := counters(data)
+		d, changed := delta(cur, p.last[name])
+		p.last[name] = cur
+		if !changed {
+			continue
+		}
+
+		h := sha256.New()
+		h.Write([]byte(name))
+		binary.LittleEndian.PutUint64(buf[:], uint64(now.UnixNano()))
+		h.Write(buf[:])
+		for _, v := range d {
+			binary.LittleEndian.PutUint64(buf[:], v)
+			h.Write(buf[:])
+		}
+		out = h.Sum(out)
+	}
+
+	if read == 0 {
+		return nil, ErrNoSystemFiles
+	}
+	return out, nil
+}
+
+// AddSystem adds a poller that reads fast-changing kernel counters,
+// such as interrupt, scheduler, disk, and network statistics, and is
+// registered as SystemSourceName. Each poll adds one event for each
+// file whose counters have changed. If cfg is nil, the defaults are
+// used.
+func (r *PollRunner) AddSystem(cfg *SystemSourceConfig) error {
+	var sc SystemSourceConfig
+	if cfg != nil {
+		sc = *cfg
+	}
+	if sc.Root == "" {
+		sc.Root = SystemRoot
+	}
+	if len(sc.Files) == 0 {
+		sc.Files = DefaultSystemFiles
+	}
+	if sc.Interval <= 0 {
+		sc.Interval = SystemInterval
+	}
+
+	return r.Add(SystemSourceName, newSystemPoller(sc.Root, sc.Files), &PollerConfig{
+		Interval: sc.Interval,
+		Entropy:  sc.Entropy,
+		Source:   sc.Source,
+	})
+}
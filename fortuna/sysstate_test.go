@@ -0,0 +1,81 @@
+package fortuna
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCounters(t *testing.T) {
+	v := counters([]byte("cpu  10 0 2345\nintr 7 x9\n"))
+	var expected = []uint64{10, 0, 2345, 7, 9}
+	if len(v) != len(expected) {
+		fmt.Fprintf(os.Stderr, "fortuna: parsed %v, expected %v\n", v, expected)
+		t.FailNow()
+	}
+	for i := range v {
+		if v[i] != expected[i] {
+			fmt.Fprintf(os.Stderr, "fortuna: parsed %v, expected %v\n", v, expected)
+			t.FailNow()
+		}
+	}
+}
+
+func TestSystemPoller(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	stat := filepath.Join(dir, "stat")
+	ioutil.WriteFile(stat, []byte("cpu 100 200\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "interrupts"), []byte("0: 5\n"), 0644)
+
+	p := newSystemPoller(dir, []string{"stat", "interrupts", "missing"})
+	out, err := p.Poll(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if len(out) != 2*MaxEventSize {
+		fmt.Fprintf(os.Stderr, "fortuna: first poll should add an event per file, got %d bytes\n", len(out))
+		t.FailNow()
+	}
+
+	// Only files whose counters changed produce events.
+	ioutil.WriteFile(stat, []byte("cpu 101 200\n"), 0644)
+	if out, err = p.Poll(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if len(out) != MaxEventSize {
+		fmt.Fprintf(os.Stderr, "fortuna: expected one changed file, got %d bytes\n", len(out))
+		t.FailNow()
+	}
+
+	p = newSystemPoller(dir, []string{"missing"})
+	if _, err = p.Poll(context.Background()); err != ErrNoSystemFiles {
+		fmt.Fprintf(os.Stderr, "fortuna: poll with no files should fail (%v)\n", err)
+		t.FailNow()
+	}
+}
+
+func TestSystemSource(t *testing.T) {
+	rng := New()
+	r := rng.StartPollers(context.Background(), nil)
+	defer r.Stop()
+	err := r.AddSystem(&SystemSourceConfig{Interval: time.Hour})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	if ss := waitForEvents(t, rng, 1); ss.Name != SystemSourceName {
+		fmt.Fprintf(os.Stderr, "fortuna: bad system source stats %+v\n", ss)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,250 @@
+package fortuna
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultDetectorPaths lists the files watched by a Detector when
+// none are configured. The boot ID changes whenever a cloned image
+// is booted, but it is kept by a VM restored from a snapshot or
+// cloned while running, so it only catches cold-booted clones; on
+// platforms that expose a VM generation counter, its path should be
+// added to the list.
+var DefaultDetectorPaths = []string{
+	"/proc/sys/kernel/random/boot_id",
+}
+
+// DetectorInterval is the default interval between checks.
+const DetectorInterval = time.Second
+
+// DetectorClockJump is the default largest difference, between one
+// check and the next, in the time elapsed on the wall clock and on
+// the monotonic clock.
+const DetectorClockJump = 5 * time.Second
+
+// ClockMarker is passed to OnDetect when a clock jump is detected.
+const ClockMarker = "clock"
+
+// freshEntropySize is the number of bytes read from the operating
+// system when reseeding after a clone is detected.
+const freshEntropySize = 64
+
+// DetectorConfig controls how a Detector watches for VM snapshot
+// restores and clones.
+type DetectorConfig struct {
+	// Paths lists the files to watch; a change in the contents of
+	// any of them is treated as a clone. If empty,
+	// DefaultDetectorPaths is used.
+	Paths []string
+
+	// Interval is the time between checks. If zero,
+	// DetectorInterval is used.
+	Interval time.Duration
+
+	// ClockJump is the largest difference tolerated between the
+	// wall clock and monotonic time elapsed since the previous
+	// check. A restored snapshot or live clone resumes with its
+	// monotonic clock where it was paused, while its wall clock is
+	// stepped forward to the current time, so a larger difference
+	// is treated as a clone. Suspend and large clock corrections
+	// also trigger a reseed. If zero, DetectorClockJump is used; if
+	// negative, the clocks are not compared.
+	ClockJump time.Duration
+
+	// OnDetect, if not nil, is called from the detector's
+	// goroutine each time a change is detected, with the path of
+	// the file that changed, or ClockMarker for a clock jump.
+	OnDetect func(path string)
+}
+
+// Detector watches for signs that the host has been restored from a
+// snapshot or cloned, in which case every copy of the PRNG would
+// continue from the same state. When a change is detected, the PRNG
+// refuses to provide random data until it has been reseeded with
+// fresh entropy from the operating system and from CPU timing
+// jitter. A Detector is started with DetectClones.
+type Detector struct {
+	rng     *Fortuna
+	cfg     DetectorConfig
+	entropy func() ([]byte, error)
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	lock   sync.Mutex
+	values map[string][]byte
+	wall   time.Time
+	mono   time.Time
+	err    error
+}
+
+// timingJitter collects n raw samples from a JitterCollector. The
+// timings vary with cache, pipeline, and scheduler state that a
+// cloned VM does not share with its siblings.
+func timingJitter(n int) []byte {
+	var samples = make([]byte, 8*n)
+	j := newJitterCollector(1)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint64(samples[8*i:], uint64(j.delta()))
+	}
+	return samples
+}
+
+// freshEntropy gathers entropy from the operating system and from
+// timing jitter.
+func freshEntropy() ([]byte, error) {
+	var p = make([]byte, freshEntropySize)
+	if _, err := io.ReadFull(rand.Reader, p); err != nil {
+		return nil, err
+	}
+	return append(p, timingJitter(256)...), nil
+}
+
+func newDetector(rng *Fortuna, cfg *DetectorConfig) *Detector {
+	d := &Detector{
+		rng:     rng,
+		entropy: freshEntropy,
+		values:  map[string][]byte{},
+	}
+	if cfg != nil {
+		d.cfg = *cfg
+	}
+	if len(d.cfg.Paths) == 0 {
+		d.cfg.Paths = DefaultDetectorPaths
+	}
+	if d.cfg.Interval <= 0 {
+		d.cfg.Interval = DetectorInterval
+	}
+	if d.cfg.ClockJump == 0 {
+		d.cfg.ClockJump = DetectorClockJump
+	}
+
+	// Record the initial values so that the first check only
+	// reports changes made after the detector was started.
+	for _, path := range d.cfg.Paths {
+		d.values[path], _ = readMarker(path)
+	}
+	d.mono = time.Now()
+	d.wall = d.mono.Round(0)
+	return d
+}
+
+// DetectClones starts a Detector watching for VM snapshot restores
+// and clones in the background. It stops when ctx is cancelled or
+// its Stop method is called. If cfg is nil, the defaults are used.
+func (rng *Fortuna) DetectClones(ctx context.Context, cfg *DetectorConfig) *Detector {
+	d := newDetector(rng, cfg)
+	d.done = make(chan struct{})
+	ctx, d.cancel = context.WithCancel(ctx)
+	go d.run(ctx)
+	return d
+}
+
+// readMarker returns the contents of a watched file. A file that
+// does not exist is treated as empty, so that the detector works on
+// hosts that do not provide every marker.
+func readMarker(path string) ([]byte, error) {
+	p, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return p, err
+}
+
+func (d *Detector) run(ctx context.Context) {
+	defer close(d.done)
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.Check()
+		}
+	}
+}
+
+// clockJumped reports whether the wall clock has moved by more than
+// the configured limit relative to the monotonic clock since the
+// previous check.
+func (d *Detector) clockJumped() bool {
+	mono := time.Now()
+	wall := mono.Round(0)
+	drift := wall.Sub(d.wall) - mono.Sub(d.mono)
+	d.wall, d.mono = wall, mono
+	if d.cfg.ClockJump < 0 {
+		return false
+	}
+	return drift > d.cfg.ClockJump || drift < -d.cfg.ClockJump
+}
+
+// Check compares the watched files against their previous contents,
+// and the wall clock against the monotonic clock. If either has
+// changed, the PRNG is marked stale and reseeded with fresh entropy.
+// If fresh entropy cannot be collected, the PRNG remains stale, and
+// the reseed is retried on the next check. Check returns true if a
+// change was detected.
+func (d *Detector) Check() (bool, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	var changed bool
+	if d.clockJumped() {
+		changed = true
+		d.rng.invalidate()
+		if d.cfg.OnDetect != nil {
+			d.cfg.OnDetect(ClockMarker)
+		}
+	}
+
+	for _, path := range d.cfg.Paths {
+		v, err := readMarker(path)
+		if err != nil {
+			continue
+		}
+		if !bytes.Equal(v, d.values[path]) {
+			d.values[path] = v
+			changed = true
+			d.rng.invalidate()
+			if d.cfg.OnDetect != nil {
+				d.cfg.OnDetect(path)
+			}
+		}
+	}
+
+	if !changed && d.err == nil {
+		return false, nil
+	}
+
+	p, err := d.entropy()
+	d.err = err
+	if err != nil {
+		return changed, err
+	}
+	d.rng.freshReseed(p)
+	zero(p)
+	return changed, nil
+}
+
+// Err returns the error from the last attempt to reseed the PRNG
+// after a change was detected, or nil if it succeeded.
+func (d *Detector) Err() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.err
+}
+
+// Stop halts the detector and waits for it to exit.
+func (d *Detector) Stop() {
+	d.cancel()
+	<-d.done
+}
@@ -0,0 +1,153 @@
+package fortuna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDetector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	marker := filepath.Join(dir, "generation")
+	if err = ioutil.WriteFile(marker, []byte("1"), 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	rng := seededRNG(t)
+	d := newDetector(rng, &DetectorConfig{
+		Paths: []string{marker, filepath.Join(dir, "missing")},
+	})
+
+	if changed, err := d.Check(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if changed {
+		fmt.Fprintf(os.Stderr, "fortuna: detector reported a change without one\n")
+		t.FailNow()
+	}
+
+	// Simulate a failure to collect fresh entropy: the PRNG must
+	// refuse to provide output until the reseed succeeds.
+	errEntropy := errors.New("no entropy")
+	d.entropy = func() ([]byte, error) { return nil, errEntropy }
+	if err = ioutil.WriteFile(marker, []byte("2"), 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	if changed, err := d.Check(); !changed || err != errEntropy {
+		fmt.Fprintf(os.Stderr, "fortuna: detector should report the change and error\n")
+		t.FailNow()
+	}
+
+	var p = make([]byte, 16)
+	if _, err = rng.Read(p); err != ErrStale {
+		fmt.Fprintf(os.Stderr, "fortuna: stale PRNG should refuse to provide output\n")
+		t.FailNow()
+	}
+
+	d.entropy = freshEntropy
+	counter := rng.reseeds()
+	if changed, err := d.Check(); changed || err != nil {
+		fmt.Fprintf(os.Stderr, "fortuna: retried reseed should succeed (%v)\n", err)
+		t.FailNow()
+	} else if rng.reseeds() != counter+1 {
+		fmt.Fprintf(os.Stderr, "fortuna: PRNG was not reseeded\n")
+		t.FailNow()
+	} else if _, err = rng.Read(p); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+}
+
+func TestDetectClones(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortuna")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	marker := filepath.Join(dir, "boot_id")
+	if err = ioutil.WriteFile(marker, []byte("a"), 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	detected := make(chan string, 1)
+	rng := seededRNG(t)
+	counter := rng.reseeds()
+	d := rng.DetectClones(context.Background(), &DetectorConfig{
+		Paths:    []string{marker},
+		Interval: 5 * time.Millisecond,
+		OnDetect: func(path string) { detected <- path },
+	})
+	defer d.Stop()
+
+	if err = ioutil.WriteFile(marker, []byte("b"), 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+
+	select {
+	case path := <-detected:
+		if path != marker {
+			fmt.Fprintf(os.Stderr, "fortuna: detected change in %s\n", path)
+			t.FailNow()
+		}
+	case <-time.After(time.Second):
+		fmt.Fprintf(os.Stderr, "fortuna: change was not detected\n")
+		t.FailNow()
+	}
+
+	d.Stop()
+	if rng.reseeds() <= counter {
+		fmt.Fprintf(os.Stderr, "fortuna: PRNG was not reseeded after detection\n")
+		t.FailNow()
+	}
+}
+
+func TestDetectorClockJump(t *testing.T) {
+	var detected []string
+	rng := seededRNG(t)
+	d := newDetector(rng, &DetectorConfig{
+		Paths:    []string{filepath.Join(os.TempDir(), "fortuna-missing")},
+		OnDetect: func(path string) { detected = append(detected, path) },
+	})
+
+	// A restored snapshot's wall clock is stepped forward while its
+	// monotonic clock carries on from where it was paused.
+	counter := rng.reseeds()
+	d.wall = d.wall.Add(-time.Hour)
+	if changed, err := d.Check(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	} else if !changed || len(detected) != 1 || detected[0] != ClockMarker {
+		fmt.Fprintf(os.Stderr, "fortuna: clock jump was not detected\n")
+		t.FailNow()
+	} else if rng.reseeds() != counter+1 {
+		fmt.Fprintf(os.Stderr, "fortuna: PRNG was not reseeded\n")
+		t.FailNow()
+	}
+
+	if changed, _ := d.Check(); changed {
+		fmt.Fprintf(os.Stderr, "fortuna: detector reported a jump without one\n")
+		t.FailNow()
+	}
+
+	d.cfg.ClockJump = -1
+	d.wall = d.wall.Add(-time.Hour)
+	if changed, _ := d.Check(); changed {
+		fmt.Fprintf(os.Stderr, "fortuna: disabled clock check reported a jump\n")
+		t.FailNow()
+	}
+}
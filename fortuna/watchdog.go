@@ -0,0 +1,119 @@
+package fortuna
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WatchdogInterval is the default interval between watchdog checks.
+const WatchdogInterval = time.Second
+
+// Watchdog periodically checks the registered sources against their
+// expected event rates, sending an EventSourceSilent to the PRNG's
+// observers when a source goes quiet and an EventSourceRecovered when
+// it starts delivering events again. Sources registered without an
+// ExpectedInterval are not watched. A Watchdog is started with
+// StartWatchdog.
+type Watchdog struct {
+	rng    *Fortuna
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	lock   sync.Mutex
+	silent map[*Source]bool
+}
+
+// StartWatchdog starts a Watchdog checking the PRNG's sources every
+// interval; if interval is zero, WatchdogInterval is used. It stops
+// when ctx is cancelled or its Stop method is called.
+func (rng *Fortuna) StartWatchdog(ctx context.Context, interval time.Duration) *Watchdog {
+	if interval <= 0 {
+		interval = WatchdogInterval
+	}
+
+	w := &Watchdog{
+		rng:    rng,
+		done:   make(chan struct{}),
+		silent: map[*Source]bool{},
+	}
+	ctx, w.cancel = context.WithCancel(ctx)
+	go w.run(ctx, interval)
+	return w
+}
+
+func (w *Watchdog) run(ctx context.Context, interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Check()
+		}
+	}
+}
+
+// Check compares each watched source against its expected rate,
+// reporting any change since the last check, and returns the names
+// of the sources that are currently silent.
+func (w *Watchdog) Check() []string {
+	now := time.Now()
+	var events []Event
+	var silent []string
+
+	w.lock.Lock()
+	w.rng.srcLock.Lock()
+	seen := map[*Source]bool{}
+	for id, src := range w.rng.ids {
+		if src == nil || src.opts.ExpectedInterval <= 0 {
+			continue
+		}
+		seen[src] = true
+
+		c := w.rng.counters[id]
+		ss := SourceStats{
+			Registered:       src.registered,
+			LastEvent:        c.lastEvent,
+			ExpectedInterval: src.opts.ExpectedInterval,
+		}
+
+		ev := Event{Time: now, Source: src.name, SourceID: src.id}
+		isSilent := ss.silent(now)
+		if isSilent {
+			silent = append(silent, src.name)
+		}
+
+		if isSilent && !w.silent[src] {
+			ev.Kind = EventSourceSilent
+			events = append(events, ev)
+		} else if !isSilent && w.silent[src] {
+			ev.Kind = EventSourceRecovered
+			events = append(events, ev)
+		}
+		w.silent[src] = isSilent
+	}
+	w.rng.srcLock.Unlock()
+
+	// Forget sources that have been closed.
+	for src := range w.silent {
+		if !seen[src] {
+			delete(w.silent, src)
+		}
+	}
+	w.lock.Unlock()
+
+	for _, ev := range events {
+		w.rng.emit(ev)
+	}
+	return silent
+}
+
+// Stop halts the watchdog and waits for it to exit.
+func (w *Watchdog) Stop() {
+	w.cancel()
+	<-w.done
+}
@@ -0,0 +1,67 @@
+package fortuna
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	rng := seededRNG(t)
+	events := make(chan Event, ObserverQueueSize)
+	remove := rng.AddObserver(ObserverFunc(func(ev Event) { events <- ev }))
+	defer remove()
+
+	src, err := rng.RegisterSourceWithOptions("upstream", &SourceOptions{
+		ExpectedInterval: 5 * time.Millisecond,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		t.FailNow()
+	}
+	rng.RegisterSource("unwatched")
+
+	w := rng.StartWatchdog(context.Background(), time.Millisecond)
+	defer w.Stop()
+
+	ev := nextEvent(t, events)
+	if ev.Kind != EventSourceSilent || ev.Source != "upstream" {
+		fmt.Fprintf(os.Stderr, "fortuna: expected silent source event, got %+v\n", ev)
+		t.FailNow()
+	} else if h := rng.Health(); h.Status != HealthDegraded || len(h.Reasons) != 1 {
+		fmt.Fprintf(os.Stderr, "fortuna: silent source should degrade health %+v\n", h)
+		t.FailNow()
+	}
+
+	src.Add([]byte{1})
+	ev = nextEvent(t, events)
+	if ev.Kind != EventSourceRecovered || ev.Source != "upstream" {
+		fmt.Fprintf(os.Stderr, "fortuna: expected recovered source event, got %+v\n", ev)
+		t.FailNow()
+	}
+}
+
+func TestWatchdogCheck(t *testing.T) {
+	rng := New()
+	src, _ := rng.RegisterSourceWithOptions("quiet", &SourceOptions{
+		ExpectedInterval: time.Millisecond,
+	})
+	w := &Watchdog{rng: rng, silent: map[*Source]bool{}}
+
+	<-time.After(5 * time.Millisecond)
+	if silent := w.Check(); len(silent) != 1 || silent[0] != "quiet" {
+		fmt.Fprintf(os.Stderr, "fortuna: expected quiet source to be silent, got %v\n", silent)
+		t.FailNow()
+	} else if st := rng.Stats(); !st.Sources[0].Silent {
+		fmt.Fprintf(os.Stderr, "fortuna: stats should report the silent source\n")
+		t.FailNow()
+	}
+
+	src.Close()
+	if silent := w.Check(); len(silent) != 0 || len(w.silent) != 0 {
+		fmt.Fprintf(os.Stderr, "fortuna: closed sources should not be watched\n")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/gokyle/gofortuna
+
+go 1.20